@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Sender sends messages to a queue.
+type Sender interface {
+	SendMessage(msg *Message) error
+}
+
+// Receiver receives messages from a queue and settles them.
+type Receiver interface {
+	GetMessage() (*Message, error)
+	UnlockMessage(msg *Message) error
+	DeleteMessage(msg *Message) error
+}
+
+// QueueAPI covers all operations of QueueClient, so application code
+// and generated mocks can depend on the interface rather than the struct.
+type QueueAPI interface {
+	Sender
+	Receiver
+
+	SendMessageContext(ctx context.Context, msg *Message) error
+	SendMessageWithResponse(msg *Message) (*SendResponse, error)
+	SendAfter(msg *Message, delay time.Duration) error
+	SendBatch(msgs []*Message) error
+	ReceiveBatch(ctx context.Context, maxMessages int, maxWait time.Duration) ([]*Message, error)
+	DrainQueue(ctx context.Context, maxMessages int, handler func(msg *Message) error) (int, error)
+
+	GetQueue(ctx context.Context) (*QueueDescription, error)
+	QueueExists(ctx context.Context) (bool, error)
+	GetQueueRuntimeInfo(ctx context.Context) (*QueueRuntimeInfo, error)
+	GetNamespaceInfo(ctx context.Context) (*NamespaceInfo, error)
+	EnsureQueue(ctx context.Context, desc QueueDescription) (EnsureAction, error)
+	EnsureTopic(ctx context.Context, desc TopicDescription) (EnsureAction, error)
+	EnsureSubscription(ctx context.Context, name string, desc SubscriptionDescription) (EnsureAction, error)
+	EnsureRetryQueues(ctx context.Context, delays ...time.Duration) (*RetryPolicy, error)
+
+	UpdateCredentials(keyName string, keyValue string)
+	WarmUp(ctx context.Context) error
+	Health() Health
+	LivenessHandler() http.Handler
+	ReadinessHandler(maxIdle time.Duration) http.Handler
+	ScalerMetricsHandler() http.Handler
+	Close() error
+}
+
+var _ QueueAPI = (*QueueClient)(nil)
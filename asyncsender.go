@@ -0,0 +1,107 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Returned by AsyncSender.SendMessage when its queue is full.
+var ErrSendQueueFull = errors.New("Send queue is full")
+
+// Sends messages on background workers so that latency-sensitive callers
+// don't block on Service Bus round trips. Failures are reported to a callback.
+//
+// Thread-safe. Create with NewAsyncSender and Close when done.
+type AsyncSender struct {
+	sender  Sender
+	onError func(msg *Message, err error)
+
+	mu      sync.RWMutex
+	closed  bool
+	pending chan *Message
+	wg      sync.WaitGroup
+}
+
+var _ Sender = (*AsyncSender)(nil)
+
+// Creates a sender queueing up to queueSize messages for the given number of workers,
+// at least one. onError is called from the workers for every message which couldn't
+// be sent; failures are logged if it is nil.
+func NewAsyncSender(sender Sender, workers int, queueSize int, onError func(msg *Message, err error)) *AsyncSender {
+
+	if workers <= 0 {
+		workers = 1
+	}
+
+	s := &AsyncSender{
+		sender:  sender,
+		onError: onError,
+		pending: make(chan *Message, queueSize),
+	}
+
+	for i := 0; i < workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+
+	return s
+}
+
+// Queues the message for sending and returns immediately.
+// Returns ErrSendQueueFull if the queue is full and ErrClientClosed after Close.
+func (s *AsyncSender) SendMessage(msg *Message) error {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return ErrClientClosed
+	}
+
+	select {
+	case s.pending <- msg:
+		return nil
+	default:
+		return ErrSendQueueFull
+	}
+}
+
+// Stops accepting messages and waits until the queued ones are sent or ctx is done.
+func (s *AsyncSender) Close(ctx context.Context) error {
+
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.pending)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *AsyncSender) work() {
+
+	defer s.wg.Done()
+
+	for msg := range s.pending {
+		if err := s.sender.SendMessage(msg); err != nil {
+			if s.onError != nil {
+				s.onError(msg, err)
+			} else {
+				logger.Error("Sending message failed", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func Test_AsyncSender(t *testing.T) {
+
+	target := &recordingSender{}
+
+	var mu sync.Mutex
+	failed := 0
+	s := NewAsyncSender(target, 3, 100, func(msg *Message, err error) {
+		mu.Lock()
+		failed++
+		mu.Unlock()
+	})
+
+	for i := 0; i < 10; i++ {
+		if err := s.SendMessage(NewMessage([]byte("hello"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(target.sent) != 10 {
+		t.Fatalf("Expected 10 sent messages but got %v", len(target.sent))
+	}
+
+	if failed != 0 {
+		t.Fatalf("Expected no failures but got %v", failed)
+	}
+
+	if err := s.SendMessage(NewMessage([]byte("hello"))); err != ErrClientClosed {
+		t.Fatalf("Expected error %v but got %v", ErrClientClosed, err)
+	}
+}
+
+func Test_AsyncSender_onError(t *testing.T) {
+
+	failed := make(chan error, 1)
+	s := NewAsyncSender(&recordingSender{err: errors.New("failed")}, 1, 1, func(msg *Message, err error) {
+		failed <- err
+	})
+
+	s.SendMessage(NewMessage([]byte("hello")))
+	s.Close(context.Background())
+
+	if err := <-failed; err == nil || err.Error() != "failed" {
+		t.Fatalf("Expected send failure to be reported but got %v", err)
+	}
+}
+
+func Test_AsyncSender_queueFull(t *testing.T) {
+
+	s := NewAsyncSender(&recordingSender{}, 0, 1, nil)
+
+	if err := s.SendMessage(NewMessage([]byte("hello"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SendMessage(NewMessage([]byte("hello"))); err != ErrSendQueueFull {
+		t.Fatalf("Expected error %v but got %v", ErrSendQueueFull, err)
+	}
+}
+
+func Test_AsyncSender_noWorkers(t *testing.T) {
+
+	target := &recordingSender{}
+	s := NewAsyncSender(target, 0, 1, nil)
+
+	if err := s.SendMessage(NewMessage([]byte("hello"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(target.sent) != 1 {
+		t.Fatalf("Expected 1 sent message but got %v", len(target.sent))
+	}
+}
@@ -0,0 +1,63 @@
+package queue
+
+import "time"
+
+// Destructive operations reported to QueueClient.Audit and RetryPolicy.Audit.
+// Deferral has no equivalent in the REST API, so it is never reported.
+const (
+	// The message was completed, deleting it from the queue.
+	AuditComplete = "complete"
+
+	// The message was unlocked, making it available again.
+	AuditAbandon = "abandon"
+
+	// The message was moved to the DeadLetter queue of a RetryPolicy.
+	// Reported to RetryPolicy.Audit, whatever the Receiver.
+	AuditDeadLetter = "deadletter"
+)
+
+// Record of a destructive operation on a message.
+type AuditEvent struct {
+	// Time the operation finished.
+	Time time.Time
+
+	// Operation, e.g. AuditComplete.
+	Operation string
+
+	// Namespace, queue and SAS policy name the request was signed with.
+	// Not set for AuditDeadLetter, as the policy doesn't know the Receiver.
+	Namespace string
+	QueueName string
+	KeyName   string
+
+	MessageId string
+	LockToken string
+
+	// Error the operation failed with, nil on success.
+	Err error
+}
+
+func (q *QueueClient) audit(operation string, msg *Message, err error) {
+
+	if q.Audit == nil {
+		return
+	}
+
+	event := newAuditEvent(operation, msg, err)
+	event.Namespace = q.Namespace
+	event.QueueName = q.QueueName
+	event.KeyName, _ = q.credentials()
+
+	q.Audit(event)
+}
+
+func newAuditEvent(operation string, msg *Message, err error) AuditEvent {
+
+	return AuditEvent{
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		MessageId: msg.Id,
+		LockToken: msg.LockToken,
+		Err:       err,
+	}
+}
@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func Test_Audit(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	var events []AuditEvent
+	cli := &QueueClient{
+		Namespace: "test",
+		QueueName: "test",
+		KeyName:   "key",
+		Audit: func(event AuditEvent) {
+			events = append(events, event)
+		},
+	}
+
+	msg := NewMessage([]byte("hello"))
+	msg.Id = "abc"
+	msg.LockToken = "lock"
+
+	SetHttpClient(fakeHttpClient(respondWith(http.StatusOK, "")))
+	if err := cli.DeleteMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	SetHttpClient(fakeHttpClient(respondWith(http.StatusNotFound, "")))
+	if err := cli.DeleteMessage(msg); err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 audit events but got %v", len(events))
+	}
+
+	e := events[0]
+	if e.Operation != AuditComplete || e.QueueName != "test" || e.KeyName != "key" || e.MessageId != "abc" || e.LockToken != "lock" || e.Err != nil || e.Time.IsZero() {
+		t.Fatalf("Unexpected audit event %+v", e)
+	}
+
+	if events[1].Err == nil {
+		t.Fatalf("Expected failed operation to be audited with its error")
+	}
+}
+
+func Test_Audit_abandonAndDeadLetter(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	SetHttpClient(fakeHttpClient(respondWith(http.StatusOK, "")))
+
+	var operations []string
+	audit := func(event AuditEvent) {
+		operations = append(operations, event.Operation)
+	}
+	cli := &QueueClient{Namespace: "test", QueueName: "test", Audit: audit}
+
+	msg := NewMessage([]byte("hello"))
+	msg.Id = "abc"
+	msg.LockToken = "lock"
+
+	if err := cli.UnlockMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &RetryPolicy{DeadLetter: &recordingSender{}, Audit: audit}
+	if err := policy.Retry(cli, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{AuditAbandon, AuditDeadLetter, AuditComplete}
+	if fmt.Sprint(operations) != fmt.Sprint(expected) {
+		t.Fatalf("Expected audited operations %v but got %v", expected, operations)
+	}
+}
+
+func Test_Audit_deadLetter(t *testing.T) {
+
+	var events []AuditEvent
+	policy := &RetryPolicy{
+		DeadLetter: &recordingSender{},
+		Audit: func(event AuditEvent) {
+			events = append(events, event)
+		},
+	}
+
+	msg := NewMessage([]byte("hello"))
+	msg.Id = "abc"
+	msg.LockToken = "lock"
+
+	// any Receiver is audited, not only QueueClient
+	if err := policy.Retry(&fakeReceiver{}, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 || events[0].Operation != AuditDeadLetter || events[0].MessageId != "abc" || events[0].LockToken != "lock" || events[0].Err != nil {
+		t.Fatalf("Expected dead-lettering to be audited but got %+v", events)
+	}
+}
@@ -0,0 +1,98 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+const contentTypeBatch = "application/vnd.microsoft.servicebus.json"
+
+type batchMessage struct {
+	Body             string            `json:"Body"`
+	BrokerProperties *brokerProperties `json:"BrokerProperties,omitempty"`
+	UserProperties   Properties        `json:"UserProperties,omitempty"`
+}
+
+// Sends several messages to a Service Bus queue in a single request.
+// The batch format carries bodies as JSON strings, so message bodies must be
+// valid UTF-8 text after the client's Transforms, and ContentType and ContentEncoding
+// are not sent.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/send-message-batch
+func (q *QueueClient) SendBatch(msgs []*Message) error {
+	return q.sendBatch(context.Background(), msgs)
+}
+
+func (q *QueueClient) sendBatch(ctx context.Context, msgs []*Message) error {
+
+	if err := q.checkClosed(); err != nil {
+		return err
+	}
+
+	batch := make([]batchMessage, len(msgs))
+	for i, msg := range msgs {
+
+		if err := q.validateSchema(msg); err != nil {
+			return SchemaError{Message: msg, Err: err}
+		}
+
+		msg, err := q.encode(msg)
+		if err != nil {
+			return err
+		}
+
+		if !utf8.Valid(msg.Body) {
+			return fmt.Errorf("Body of message %v in the batch is not valid UTF-8 text", i)
+		}
+
+		msg, err = q.sign(msg)
+		if err != nil {
+			return err
+		}
+
+		b := &brokerProperties{}
+		b.CopyFromMessage(msg)
+		if q.DerivePartitionKey && b.PartitionKey == "" {
+			b.PartitionKey = b.SessionId
+		}
+
+		batch[i] = batchMessage{
+			Body:             string(msg.Body),
+			BrokerProperties: b,
+			UserProperties:   msg.Properties,
+		}
+	}
+
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return wrap(err, "Batch encoding failed")
+	}
+
+	req, err := q.createRequestWithBody("messages/", "POST", body)
+	if err != nil {
+		return wrap(err, "Request create failed")
+	}
+	req.Header.Set(headerContentType, contentTypeBatch)
+
+	resp, err := q.do("send_batch", q.getClient(), req.WithContext(ctx))
+
+	if err != nil {
+		q.countError("send_batch", err)
+		return wrap(err, "Sending POST createRequest failed")
+	}
+
+	defer resp.Body.Close()
+
+	err = handleStatusCode(resp)
+	q.logResponse("send_batch", nil, resp.StatusCode, err)
+
+	if err != nil {
+		q.countError("send_batch", err)
+		return err
+	}
+
+	q.countMessages(counterSend, int64(len(msgs)))
+	return nil
+}
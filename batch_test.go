@@ -0,0 +1,46 @@
+package queue
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func Test_SendBatch(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	var sent *http.Request
+	var body string
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		sent = req
+		b, _ := ioutil.ReadAll(req.Body)
+		body = string(b)
+		return respondWith(201, "")(req)
+	}))
+
+	msg1 := NewMessage([]byte("first"))
+	msg1.Properties.Set("Color", "Red")
+	msg2 := NewMessage([]byte("second"))
+	msg2.Label = "M2"
+
+	if err := q.SendBatch([]*Message{msg1, msg2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent.Header.Get("Content-Type") != contentTypeBatch {
+		t.Fatalf("Expected Content-Type %s but got %s", contentTypeBatch, sent.Header.Get("Content-Type"))
+	}
+
+	expected := `[{"Body":"first","BrokerProperties":{},"UserProperties":{"Color":"Red"}},{"Body":"second","BrokerProperties":{"Label":"M2"}}]`
+	if body != expected {
+		t.Fatalf("Expected body %s but got %s", expected, body)
+	}
+}
+
+func Test_SendBatch_binary(t *testing.T) {
+
+	if err := q.SendBatch([]*Message{NewMessage([]byte{0xff, 0xfe})}); err == nil {
+		t.Fatal("Expected error for a body which is not UTF-8 text")
+	}
+}
@@ -0,0 +1,111 @@
+package queue
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// Benchmarks run against the namespace configured through the SERVICEBUS_* environment
+// variables if set, see NewClientFromEnvironment, and against canned responses otherwise,
+// which measures the client's own overhead and allocations:
+//
+//	go test -run XXX -bench . -benchmem
+
+const benchmarkBodySize = 1024
+
+func benchmarkClient(b *testing.B) *QueueClient {
+
+	if os.Getenv(EnvConnectionString) != "" || os.Getenv(EnvNamespace) != "" {
+		cli, err := NewClientFromEnvironment()
+		if err != nil {
+			b.Fatal(err)
+		}
+		cli.Timeout = 5
+		return cli
+	}
+
+	body := string(bytes.Repeat([]byte("x"), benchmarkBodySize))
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == "DELETE":
+			return respondWith(200, "")(req)
+		case req.Method == "POST" && req.URL.Path == "/test/messages/head":
+			resp, _ := respondWith(201, body)(req)
+			resp.Header.Set(headerBrokerProperties, `{"MessageId":"abc","LockToken":"token","DeliveryCount":1,"LockedUntilUtc":"Sun, 06 Nov 2094 08:49:37 GMT"}`)
+			return resp, nil
+		}
+		return respondWith(201, "")(req)
+	}))
+	b.Cleanup(func() { SetHttpClient(nil) })
+
+	return &QueueClient{Namespace: "test", KeyName: "key", KeyValue: "keyvalue", QueueName: "test"}
+}
+
+func BenchmarkSendMessage(b *testing.B) {
+
+	cli := benchmarkClient(b)
+	msg := NewMessage(bytes.Repeat([]byte("x"), benchmarkBodySize))
+	msg.SetProperty("Tenant", "contoso")
+
+	b.SetBytes(benchmarkBodySize)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := cli.SendMessage(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSendBatch(b *testing.B) {
+
+	cli := benchmarkClient(b)
+	batch := make([]*Message, 10)
+	for i := range batch {
+		batch[i] = NewMessage(bytes.Repeat([]byte("x"), benchmarkBodySize))
+	}
+
+	b.SetBytes(int64(len(batch)) * benchmarkBodySize)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := cli.SendBatch(batch); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Measures a receive followed by completing the message, the cost of processing one message.
+// Against a namespace, messages are sent ahead outside of the timer.
+func BenchmarkReceiveAndComplete(b *testing.B) {
+
+	cli := benchmarkClient(b)
+
+	if httpClientOverride == nil {
+		batch := []*Message{NewMessage(bytes.Repeat([]byte("x"), benchmarkBodySize))}
+		for i := 0; i < b.N; i++ {
+			if err := cli.SendBatch(batch); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.SetBytes(benchmarkBodySize)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		msg, err := cli.GetMessage()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if err := cli.DeleteMessage(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
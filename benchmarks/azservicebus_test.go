@@ -0,0 +1,98 @@
+//go:build azservicebus
+
+package benchmarks
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+	"github.com/g-rad/go-azurequeue"
+)
+
+// Returns an azservicebus client and the name of the queue the package's client would use.
+func sdkClient(b *testing.B) (*azservicebus.Client, string) {
+
+	cs := os.Getenv(queue.EnvConnectionString)
+	if cs == "" {
+		b.Skip("SERVICEBUS_CONNECTION_STRING is not set")
+	}
+
+	cli, err := queue.NewClientFromEnvironment()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	client, err := azservicebus.NewClientFromConnectionString(cs, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { client.Close(context.Background()) })
+
+	return client, cli.QueueName
+}
+
+func BenchmarkAzservicebus_SendMessage(b *testing.B) {
+
+	client, queueName := sdkClient(b)
+	ctx := context.Background()
+
+	sender, err := client.NewSender(queueName, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer sender.Close(ctx)
+
+	b.SetBytes(bodySize)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := sender.SendMessage(ctx, &azservicebus.Message{Body: body}, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Messages are sent ahead outside of the timer.
+func BenchmarkAzservicebus_ReceiveAndComplete(b *testing.B) {
+
+	client, queueName := sdkClient(b)
+	ctx := context.Background()
+
+	sender, err := client.NewSender(queueName, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer sender.Close(ctx)
+
+	for i := 0; i < b.N; i++ {
+		if err := sender.SendMessage(ctx, &azservicebus.Message{Body: body}, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	receiver, err := client.NewReceiverForQueue(queueName, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer receiver.Close(ctx)
+
+	b.SetBytes(bodySize)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		msgs, err := receiver.ReceiveMessages(ctx, 1, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, msg := range msgs {
+			if err := receiver.CompleteMessage(ctx, msg, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
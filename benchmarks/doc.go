@@ -0,0 +1,13 @@
+// Package benchmarks measures the client against a live namespace and optionally
+// compares it with the Azure SDK's azservicebus package.
+//
+// It is a separate module so that the root package doesn't depend on the Azure SDK.
+// Configure the namespace through the SERVICEBUS_* variables, see queue.NewClientFromEnvironment,
+// then resolve the dependencies once and run:
+//
+//	go mod tidy
+//	go test -run XXX -bench . -benchmem
+//	go test -run XXX -bench . -benchmem -tags azservicebus
+//
+// The azservicebus benchmarks need SERVICEBUS_CONNECTION_STRING.
+package benchmarks
@@ -0,0 +1,5 @@
+module github.com/g-rad/go-azurequeue/benchmarks
+
+go 1.21
+
+require github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus v1.7.1
@@ -0,0 +1,69 @@
+package benchmarks
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/g-rad/go-azurequeue"
+)
+
+const bodySize = 1024
+
+var body = bytes.Repeat([]byte("x"), bodySize)
+
+func queueClient(b *testing.B) *queue.QueueClient {
+
+	if os.Getenv(queue.EnvConnectionString) == "" && os.Getenv(queue.EnvNamespace) == "" {
+		b.Skip("SERVICEBUS_* variables are not set")
+	}
+
+	cli, err := queue.NewClientFromEnvironment()
+	if err != nil {
+		b.Fatal(err)
+	}
+	cli.Timeout = 5
+	return cli
+}
+
+func BenchmarkQueue_SendMessage(b *testing.B) {
+
+	cli := queueClient(b)
+
+	b.SetBytes(bodySize)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := cli.SendMessage(queue.NewMessage(body)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Messages are sent ahead outside of the timer.
+func BenchmarkQueue_ReceiveAndComplete(b *testing.B) {
+
+	cli := queueClient(b)
+
+	for i := 0; i < b.N; i++ {
+		if err := cli.SendMessage(queue.NewMessage(body)); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.SetBytes(bodySize)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		msg, err := cli.GetMessage()
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		if err := cli.DeleteMessage(msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Flush interval of buffered senders created with a non-positive interval.
+const DefaultBufferFlushInterval = time.Second
+
+// Batch size of buffered senders created with a non-positive batch size.
+const DefaultBufferMaxBatchSize = 100
+
+// Accumulates messages and sends them as batches once MaxBatchSize messages are
+// buffered or FlushInterval has passed, greatly improving throughput for
+// high-volume producers. See QueueClient.SendBatch for the limitations of batches.
+//
+// Messages which couldn't be sent are not retried. The failure of the message whose
+// SendMessage call triggered the flush is returned to that caller, all other unsent
+// messages are handed to OnError, or logged and dropped if it isn't set.
+//
+// Thread-safe. Create with NewBufferedSender and Close when done.
+type BufferedSender struct {
+	Queue *QueueClient
+
+	// Number of buffered messages triggering a flush.
+	MaxBatchSize int
+
+	// Maximal time a message stays buffered.
+	FlushInterval time.Duration
+
+	// Optional callback for messages which couldn't be sent and whose sender
+	// isn't waiting for the flush, e.g. on interval flushes. It receives the
+	// unsent messages, which are dropped afterwards. Failures are logged if not
+	// set. Must be set before the first message is sent.
+	OnError func(batch []*Message, err error)
+
+	mu      sync.Mutex
+	closed  bool
+	buffer  []*Message
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+var _ Sender = (*BufferedSender)(nil)
+
+// Creates a sender and starts its interval flushing. A maxBatchSize of zero
+// uses DefaultBufferMaxBatchSize and a flushInterval of zero DefaultBufferFlushInterval.
+func NewBufferedSender(q *QueueClient, maxBatchSize int, flushInterval time.Duration) *BufferedSender {
+
+	if maxBatchSize <= 0 {
+		maxBatchSize = DefaultBufferMaxBatchSize
+	}
+
+	if flushInterval <= 0 {
+		flushInterval = DefaultBufferFlushInterval
+	}
+
+	s := &BufferedSender{
+		Queue:         q,
+		MaxBatchSize:  maxBatchSize,
+		FlushInterval: flushInterval,
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+// Buffers the message. If the buffer is full, it is flushed right away and
+// the error is returned if the message couldn't be sent.
+// Returns ErrClientClosed after Close.
+func (s *BufferedSender) SendMessage(msg *Message) error {
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClientClosed
+	}
+	s.buffer = append(s.buffer, msg)
+	full := len(s.buffer) >= s.MaxBatchSize
+	s.mu.Unlock()
+
+	if !full {
+		return nil
+	}
+
+	unsent, err := s.flush(context.Background())
+
+	for i, m := range unsent {
+		if m == msg {
+			s.failed(append(unsent[:i:i], unsent[i+1:]...), err)
+			return err
+		}
+	}
+
+	s.failed(unsent, err)
+	return nil
+}
+
+// Sends all buffered messages. Messages which couldn't be sent are handed
+// to OnError and the error returned.
+func (s *BufferedSender) Flush(ctx context.Context) error {
+
+	unsent, err := s.flush(ctx)
+	s.failed(unsent, err)
+	return err
+}
+
+// Sends all buffered messages and returns the ones which couldn't be sent
+// along with the last error.
+func (s *BufferedSender) flush(ctx context.Context) ([]*Message, error) {
+
+	s.mu.Lock()
+	batch := s.buffer
+	s.buffer = nil
+	s.mu.Unlock()
+
+	var unsent []*Message
+	var lastErr error
+
+	for len(batch) > 0 {
+		n := len(batch)
+		if s.MaxBatchSize > 0 && n > s.MaxBatchSize {
+			n = s.MaxBatchSize
+		}
+
+		if err := s.Queue.sendBatch(ctx, batch[:n]); err != nil {
+			unsent = append(unsent, batch[:n]...)
+			lastErr = err
+		}
+		batch = batch[n:]
+	}
+
+	return unsent, lastErr
+}
+
+// Reports messages which couldn't be sent to OnError, or logs the failure.
+func (s *BufferedSender) failed(unsent []*Message, err error) {
+
+	if len(unsent) == 0 {
+		return
+	}
+
+	if s.OnError != nil {
+		s.OnError(unsent, err)
+	} else {
+		logger.Error("Dropping "+strconv.Itoa(len(unsent))+" buffered messages which couldn't be sent", err)
+	}
+}
+
+// Stops interval flushing and sends the remaining messages.
+// Messages sent afterwards are rejected with ErrClientClosed.
+func (s *BufferedSender) Close(ctx context.Context) error {
+
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+	s.mu.Unlock()
+	<-s.stopped
+
+	return s.Flush(ctx)
+}
+
+func (s *BufferedSender) run() {
+
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.Flush(context.Background())
+		}
+	}
+}
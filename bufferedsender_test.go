@@ -0,0 +1,171 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Records the sizes of sent batches.
+func recordBatches(code int) (*[]int, *sync.Mutex) {
+
+	var mu sync.Mutex
+	var batches []int
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		b, _ := ioutil.ReadAll(req.Body)
+		var batch []batchMessage
+		json.Unmarshal(b, &batch)
+
+		mu.Lock()
+		batches = append(batches, len(batch))
+		mu.Unlock()
+
+		return respondWith(code, "")(req)
+	}))
+
+	return &batches, &mu
+}
+
+func Test_BufferedSender_size(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	batches, mu := recordBatches(201)
+
+	s := NewBufferedSender(&QueueClient{Namespace: "test", QueueName: "test"}, 2, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		if err := s.SendMessage(NewMessage([]byte("hello"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(*batches) != 3 || (*batches)[0] != 2 || (*batches)[1] != 2 || (*batches)[2] != 1 {
+		t.Fatalf("Expected batches of 2, 2 and 1 messages but got %v", *batches)
+	}
+}
+
+func Test_BufferedSender_interval(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	batches, mu := recordBatches(201)
+
+	s := NewBufferedSender(&QueueClient{Namespace: "test", QueueName: "test"}, 100, 10*time.Millisecond)
+	defer s.Close(context.Background())
+
+	s.SendMessage(NewMessage([]byte("hello")))
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		flushed := len(*batches) == 1
+		mu.Unlock()
+
+		if flushed {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatal("Expected buffered message to be flushed after the interval")
+}
+
+func Test_BufferedSender_onError(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	recordBatches(500)
+
+	failed := make(chan int, 1)
+	s := NewBufferedSender(&QueueClient{Namespace: "test", QueueName: "test"}, 100, 10*time.Millisecond)
+	s.OnError = func(batch []*Message, err error) { failed <- len(batch) }
+	defer s.Close(context.Background())
+
+	s.SendMessage(NewMessage([]byte("hello")))
+
+	select {
+	case n := <-failed:
+		if n != 1 {
+			t.Fatalf("Expected 1 unsent message but got %v", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected OnError to be called")
+	}
+}
+
+func Test_BufferedSender_sendFailure(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	recordBatches(500)
+
+	var mu sync.Mutex
+	var dropped []*Message
+	s := NewBufferedSender(&QueueClient{Namespace: "test", QueueName: "test"}, 2, 0)
+	s.OnError = func(batch []*Message, err error) {
+		mu.Lock()
+		dropped = append(dropped, batch...)
+		mu.Unlock()
+	}
+	defer s.Close(context.Background())
+
+	if s.FlushInterval != DefaultBufferFlushInterval {
+		t.Fatalf("Expected flush interval %v but got %v", DefaultBufferFlushInterval, s.FlushInterval)
+	}
+
+	other, own := NewMessage([]byte("other")), NewMessage([]byte("own"))
+
+	if err := s.SendMessage(other); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SendMessage(own); err == nil {
+		t.Fatal("Expected error sending the batch")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dropped) != 1 || dropped[0] != other {
+		t.Fatalf("Expected only the other caller's message handed to OnError but got %v", dropped)
+	}
+}
+
+func Test_BufferedSender_closed(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	batches, mu := recordBatches(201)
+
+	s := NewBufferedSender(&QueueClient{Namespace: "test", QueueName: "test"}, 0, time.Hour)
+
+	if s.MaxBatchSize != DefaultBufferMaxBatchSize {
+		t.Fatalf("Expected batch size %d but got %d", DefaultBufferMaxBatchSize, s.MaxBatchSize)
+	}
+
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SendMessage(NewMessage([]byte("hello"))); err != ErrClientClosed {
+		t.Fatalf("Expected error %v but got %v", ErrClientClosed, err)
+	}
+
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(*batches) != 0 {
+		t.Fatalf("Expected no batches but got %v", *batches)
+	}
+}
@@ -2,15 +2,20 @@ package queue
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
 	"net/textproto"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,7 +27,9 @@ const Rfc2616Time = "Mon, 02 Jan 2006 15:04:05 MST"
 const (
 	headerBrokerProperties = "BrokerProperties"
 	headerContentType      = "Content-Type"
+	headerContentEncoding  = "Content-Encoding"
 	headerDate             = "Date"
+	headerRequestId        = "X-Ms-Request-Id"
 )
 
 type HttpClient interface {
@@ -62,6 +69,7 @@ func (p Properties) Set(key, value string) {
 // See https://docs.microsoft.com/en-us/rest/api/servicebus/message-headers-and-properties
 type Message struct {
 	ContentType             string
+	ContentEncoding         string
 	CorrelationId           string
 	SessionId               string
 	DeliveryCount           int
@@ -80,7 +88,20 @@ type Message struct {
 
 	Properties Properties
 
+	// System annotations (x-ms-* and x-opt-* headers) of a received message.
+	SystemProperties Properties
+
+	// Names of BrokerProperties fields of a received message which were skipped,
+	// as their values had an unexpected type.
+	SkippedBrokerProperties []string
+
 	Body []byte
+
+	// Body of a received message decoded by the client's Codecs.
+	decoded interface{}
+
+	// Individual values of custom properties received in several headers.
+	multiValued map[string][]string
 }
 
 func NewMessage(body []byte) *Message {
@@ -91,6 +112,73 @@ func NewMessage(body []byte) *Message {
 	}
 }
 
+// Returns a deep copy of the message.
+func (m *Message) Clone() *Message {
+
+	c := *m
+
+	if m.Properties != nil {
+		c.Properties = Properties{}
+		for k, v := range m.Properties {
+			c.Properties[k] = v
+		}
+	}
+
+	if m.SystemProperties != nil {
+		c.SystemProperties = Properties{}
+		for k, v := range m.SystemProperties {
+			c.SystemProperties[k] = v
+		}
+	}
+
+	if m.SkippedBrokerProperties != nil {
+		c.SkippedBrokerProperties = append([]string(nil), m.SkippedBrokerProperties...)
+	}
+
+	if m.Body != nil {
+		c.Body = append([]byte(nil), m.Body...)
+	}
+
+	if m.multiValued != nil {
+		c.multiValued = map[string][]string{}
+		for k, v := range m.multiValued {
+			c.multiValued[k] = append([]string(nil), v...)
+		}
+	}
+
+	return &c
+}
+
+// Returns the value of the custom property with the given name.
+// Lookups are case insensitive: Azure returns property names in canonical
+// MIME header form, so a property sent as "myProp" is received as "Myprop".
+func (m *Message) GetProperty(key string) string {
+	return m.Properties.Get(key)
+}
+
+// Sets the custom property with the given name, replacing any value stored
+// under a different casing of the same name. The name is stored in
+// canonical MIME header form, e.g. "myProp" becomes "Myprop".
+func (m *Message) SetProperty(key, value string) {
+	if m.Properties == nil {
+		m.Properties = Properties{}
+	}
+	m.Properties.Set(key, value)
+}
+
+// Canonicalization applied to the resource URI signed by SAS tokens.
+type UriCasing int
+
+const (
+	// Lower case the whole query-escaped URI, including the escape sequences
+	// and the entity path. This is the default.
+	UriLowerCase UriCasing = iota
+
+	// Query-escape the URI but keep its casing, for entities whose names the
+	// service compares case-sensitively.
+	UriPreserveCase
+)
+
 // Thread-safe client for Azure Service Bus Queue.
 type QueueClient struct {
 	// Service Bus Namespace e.g. https://<yournamespace>.servicebus.windows.net
@@ -102,14 +190,128 @@ type QueueClient struct {
 	// Policy value.
 	KeyValue string
 
+	// Refuse to sign requests unless the Go cryptographic module runs in FIPS 140 mode
+	// (Go 1.24+ with GODEBUG=fips140=on) and the SAS keys are long enough for it.
+	RequireFIPS bool
+
+	// Optional secondary policy name and value. Once a request signed with the primary
+	// key is rejected as unauthorized, it is retried and all subsequent requests are
+	// signed with the secondary key until UpdateCredentials is called.
+	SecondaryKeyName  string
+	SecondaryKeyValue string
+
 	// Name of the queue.
 	QueueName string
 
-	// Request timeout in seconds.
+	// Server-side long poll timeout in seconds for receive operations.
 	Timeout int
 
+	// Timeout of the default HTTP client. Zero means no timeout.
+	// It must exceed Timeout by more than LongPollSlack, otherwise receives fail locally.
+	HttpTimeout time.Duration
+
+	// Maximal size in bytes of response bodies read into memory, such as the bodies of
+	// received messages. Zero means no limit. Received messages exceeding it are unlocked
+	// and ResponseTooLargeError returned.
+	MaxResponseBodySize int64
+
+	// Optional function dialing the connections of the default HTTP client, e.g. to pin
+	// a private endpoint or instrument connections.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// Optional resolver of the default HTTP client's dialer, e.g. for custom DNS.
+	// Ignored if DialContext is set.
+	Resolver *net.Resolver
+
+	// Content-Type of sent messages which don't specify one, e.g. application/json.
+	DefaultContentType string
+
+	// Canonicalization of the resource URI in SAS tokens. Defaults to UriLowerCase.
+	SasUriCasing UriCasing
+
+	// Send messages with a SessionId but no PartitionKey using the SessionId as PartitionKey,
+	// as partitioned session queues require the two to match.
+	DerivePartitionKey bool
+
+	// Optional API Management gateway URL e.g. https://contoso.azure-api.net/servicebus
+	// Requests are sent to <GatewayURL>/<QueueName>/... while the SAS token is still
+	// signed for the Service Bus resource URI.
+	GatewayURL string
+
+	// Headers added to every request, e.g. gateway API keys or routing headers.
+	// Message properties and headers set by the client take precedence.
+	Headers map[string]string
+
+	// Optional registry validating bodies of sent and received messages
+	// which carry a schema id, see Message.SetSchemaId.
+	SchemaRegistry SchemaRegistry
+
+	// If set, receives issue a second request when the first one hasn't returned
+	// within HedgeDelay and take whichever returns a message first.
+	// A message received by the slower request is unlocked.
+	HedgeDelay time.Duration
+
+	// Optional signer of sent messages.
+	Signer *MessageSigner
+
+	// Body transforms applied in order to sent messages and in reverse order
+	// to received messages, e.g. compression and then encryption.
+	Transforms []BodyTransform
+
+	// Optional codecs decoding bodies of received messages by Content-Type,
+	// see Message.DecodedBody.
+	Codecs CodecRegistry
+
+	// Optional resolver of keys verifying the signatures of received messages.
+	// If set, receives return SignatureError for unsigned or tampered messages.
+	SignatureKeys SignatureKeyResolver
+
+	// Optional store of processed message ids. Received messages whose MessageId
+	// was already completed within the store's window are completed again and skipped.
+	Dedupe DedupeStore
+
+	// Optional tracker notified of received and settled messages.
+	Tracker SettlementTracker
+
+	// Optional callback invoked on its own goroutine shortly before the lock
+	// of a received message expires, unless the message has been settled by then.
+	OnLockExpiring func(msg *Message)
+
+	// How long before the lock expiry OnLockExpiring is called.
+	// Defaults to DefaultLockExpiringLead.
+	LockExpiringLead time.Duration
+
+	// Optional hook receiving an AuditEvent for every attempt to complete or unlock
+	// a message, for compliance records of message disposal. Dead-lettering is
+	// reported to RetryPolicy.Audit.
+	Audit func(event AuditEvent)
+
+	// Messages sent with SendMessageContext without a CorrelationId get the one
+	// of the inbound message attached to the context, see ContextWithMessage.
+	PropagateCorrelationId bool
+
+	// Guarantees message bodies are never part of log entries or error messages
+	// of the package, e.g. for GDPR or PCI workloads. Errors of callbacks which may
+	// quote the body, such as schema validation, are reported without details.
+	NeverLogBody bool
+
 	mu         sync.Mutex
 	httpClient HttpClient
+
+	credMu       sync.RWMutex
+	useSecondary bool
+
+	// Names of skipped BrokerProperties fields already logged, so each is only logged once.
+	loggedSkipped sync.Map
+
+	healthMu    sync.Mutex
+	lastSuccess time.Time
+	lastReceive time.Time
+
+	lockMu     sync.Mutex
+	lockTimers map[string]*time.Timer
+
+	closed chan struct{}
 }
 
 // This operation atomically retrieves and locks a message from a queue or subscription for processing.
@@ -122,121 +324,413 @@ type QueueClient struct {
 
 // For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/peek-lock-message-non-destructive-read
 func (q *QueueClient) GetMessage() (*Message, error) {
+	return q.receive(context.Background(), q.Timeout)
+}
+
+// Receives up to maxMessages messages, polling the queue as many times as needed
+// until either maxMessages messages have been received or maxWait has elapsed,
+// whichever happens first. Each poll waits on the server for no longer than
+// the remaining time.
+//
+// If an error occurs the messages received so far are returned along with it,
+// so that their locks can still be settled. maxMessages must be at least 1.
+func (q *QueueClient) ReceiveBatch(ctx context.Context, maxMessages int, maxWait time.Duration) ([]*Message, error) {
+
+	if maxMessages < 1 {
+		return nil, fmt.Errorf("maxMessages must be at least 1 but is %v", maxMessages)
+	}
+
+	deadline := time.Now().Add(maxWait)
+	messages := make([]*Message, 0, maxMessages)
 
-	req, err := q.createRequest("messages/head?timeout="+strconv.Itoa(q.Timeout), "POST")
+	for len(messages) < maxMessages {
+
+		if err := ctx.Err(); err != nil {
+			return messages, err
+		}
+
+		timeout := int(time.Until(deadline) / time.Second)
+		if timeout < 0 {
+			timeout = 0
+		}
+
+		msg, err := q.receive(ctx, timeout)
+
+		if _, ok := err.(NoMessagesAvailableError); ok {
+			if timeout == 0 {
+				break
+			}
+			continue
+		}
+
+		if err != nil {
+			return messages, err
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+// Retrieves and locks the next message, hedging the request and
+// completing already processed messages if configured.
+func (q *QueueClient) receive(ctx context.Context, timeout int) (*Message, error) {
+
+	for {
+		var msg *Message
+		var err error
+
+		if q.HedgeDelay > 0 {
+			msg, err = q.getMessageHedged(ctx, timeout)
+		} else {
+			msg, err = q.getMessage(ctx, timeout)
+		}
+
+		if err != nil || !q.isDuplicate(msg) {
+			return msg, err
+		}
+
+		logger.DebugFields("Completing already processed message", q.logFields("receive", msg))
+		if err := q.DeleteMessage(msg); err != nil {
+			q.logError("Completing duplicate message failed", "complete", msg, err)
+		}
+	}
+}
+
+// Retrieves and locks the next message, waiting up to timeout seconds on the server.
+func (q *QueueClient) getMessage(ctx context.Context, timeout int) (*Message, error) {
+
+	if err := q.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := q.withCancelOnClose(ctx)
+	defer cancel()
+
+	client := q.getClient()
+	if err := validateTimeout(client, timeout); err != nil {
+		return nil, err
+	}
+
+	req, err := q.createRequest("messages/head?timeout="+strconv.Itoa(timeout), "POST")
 
 	if err != nil {
 		return nil, wrap(err, "Request create failed")
 	}
-	resp, err := q.getClient().Do(req)
+	resp, err := q.do("receive", client, req.WithContext(ctx))
 
 	if err != nil {
+		q.countError("receive", err)
 		return nil, wrap(err, "Sending POST createRequest failed")
 	}
 
 	defer resp.Body.Close()
 
 	if err := handleStatusCode(resp); err != nil {
+		q.logResponse("receive", nil, resp.StatusCode, err)
+		q.countError("receive", err)
+		return nil, err
+	}
+
+	msg, err := parseMessage(resp, q.MaxResponseBodySize)
+	if _, ok := err.(ResponseTooLargeError); ok && msg.LockToken != "" {
+		// redeliveries count towards MaxDeliveryCount, so the message ends up dead-lettered
+		q.logError("Received message is too large", "receive", msg, err)
+		q.unlockAfterFailure(msg)
+	}
+
+	if err != nil {
+		q.countError("receive", err)
 		return nil, err
 	}
 
-	return parseMessage(resp)
+	q.logResponse("receive", msg, resp.StatusCode, nil)
+	q.logSkippedProperties(msg)
+
+	q.countMessages(counterReceive, 1)
+
+	if q.Tracker != nil {
+		q.Tracker.Received(msg)
+	}
+
+	q.watchLock(msg)
+
+	if err := q.verify(msg); err != nil {
+		return nil, SignatureError{Message: msg, Err: err}
+	}
+
+	if err := q.decode(msg); err != nil {
+		return nil, TransformError{Message: msg, Err: err}
+	}
+
+	if err := q.validateSchema(msg); err != nil {
+		return nil, SchemaError{Message: msg, Err: err}
+	}
+
+	if err := q.decodeValue(msg); err != nil {
+		return nil, TransformError{Message: msg, Err: err}
+	}
+
+	return msg, nil
 }
 
 // Sends message to a Service Bus queue.
 func (q *QueueClient) SendMessage(msg *Message) error {
+	_, err := q.SendMessageWithResponse(msg)
+	return err
+}
+
+// Sends message to a Service Bus queue to be enqueued after the delay,
+// setting ScheduledEnqueueTimeUtc of the sent copy from the current time.
+func (q *QueueClient) SendAfter(msg *Message, delay time.Duration) error {
+
+	scheduled := msg.Clone()
+	scheduled.ScheduledEnqueueTimeUtc = time.Now().UTC().Add(delay)
+	return q.SendMessage(scheduled)
+}
+
+// Details of the service response to a sent message.
+type SendResponse struct {
+	// HTTP status code, 201 on success.
+	StatusCode int
+
+	// Id the service assigned to the request, if reported.
+	RequestId string
+
+	// Time the service accepted the message, if reported.
+	Date time.Time
+
+	// Sequence number assigned to the message, if reported.
+	SequenceNumber int64
+}
+
+// Sends message to a Service Bus queue and returns the details of the service response,
+// e.g. to record proof of enqueue.
+func (q *QueueClient) SendMessageWithResponse(msg *Message) (*SendResponse, error) {
+	return q.sendMessage(context.Background(), msg)
+}
+
+func (q *QueueClient) sendMessage(ctx context.Context, msg *Message) (*SendResponse, error) {
+	if err := q.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	if err := q.validateSchema(msg); err != nil {
+		return nil, SchemaError{Message: msg, Err: err}
+	}
+
+	msg, err := q.encode(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err = q.sign(msg)
+	if err != nil {
+		return nil, err
+	}
+
 	req, err := q.createRequestFromMessage("messages/", "POST", msg)
 
 	if err != nil {
-		return wrap(err, "Request create failed")
+		return nil, wrap(err, "Request create failed")
 	}
 
-	resp, err := q.getClient().Do(req)
+	resp, err := q.do("send", q.getClient(), req.WithContext(ctx))
 
 	if err != nil {
-		return wrap(err, "Sending POST createRequest failed")
+		q.countError("send", err)
+		return nil, wrap(err, "Sending POST createRequest failed")
 	}
 
 	defer resp.Body.Close()
 
-	return handleStatusCode(resp)
+	err = handleStatusCode(resp)
+	q.logResponse("send", msg, resp.StatusCode, err)
+
+	if err != nil {
+		q.countError("send", err)
+		return nil, err
+	}
+
+	q.countMessages(counterSend, 1)
+	return parseSendResponse(resp), nil
 }
 
 // Unlocks a message for processing by other receivers on a specified subscription.
 // This operation deletes the lock object, causing the message to be unlocked.
 // Before the operation is called, a receiver must first lock the message.
+// Returns InvalidLockError without making a request if the message has no Id or LockToken
+// or its lock has expired according to LockedUntilUtc.
 //
 // For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/unlock-message
 func (q *QueueClient) UnlockMessage(msg *Message) error {
+	err := q.unlockMessage(msg)
+	q.audit(AuditAbandon, msg, err)
+	return err
+}
+
+func (q *QueueClient) unlockMessage(msg *Message) error {
+	if err := q.checkClosed(); err != nil {
+		return err
+	}
+
+	if err := validateLock(msg, clock.Now()); err != nil {
+		return err
+	}
+
 	req, err := q.createRequest("messages/"+msg.Id+"/"+msg.LockToken, "PUT")
 
 	if err != nil {
 		return wrap(err, "Request create failed")
 	}
 
-	resp, err := q.getClient().Do(req)
+	resp, err := q.do("abandon", q.getClient(), req)
 
 	if err != nil {
+		q.countError("abandon", err)
 		return wrap(err, "Sending PUT createRequest failed")
 	}
 
 	defer resp.Body.Close()
 
-	return handleStatusCode(resp)
+	err = handleStatusCode(resp)
+	q.logResponse("abandon", msg, resp.StatusCode, err)
+
+	if err != nil {
+		q.countError("abandon", err)
+		return err
+	}
+
+	q.countMessages(counterAbandon, 1)
+
+	if q.Tracker != nil {
+		q.Tracker.Settled(msg, Abandoned)
+	}
+
+	q.unwatchLock(msg)
+
+	return nil
 }
 
 // This operation completes the processing of a locked message and deletes it from the queue or subscription.
 // This operation should only be called after successfully processing a previously locked message,
 // in order to maintain At-Least-Once delivery assurances.
+// Returns InvalidLockError without making a request if the message has no Id or LockToken
+// or its lock has expired according to LockedUntilUtc.
 //
 // For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/delete-message
 func (q *QueueClient) DeleteMessage(msg *Message) error {
+	err := q.deleteMessage(msg)
+	q.audit(AuditComplete, msg, err)
+	return err
+}
+
+func (q *QueueClient) deleteMessage(msg *Message) error {
+	if err := q.checkClosed(); err != nil {
+		return err
+	}
+
+	if err := validateLock(msg, clock.Now()); err != nil {
+		return err
+	}
+
 	req, err := q.createRequest("messages/"+msg.Id+"/"+msg.LockToken, "DELETE")
 
 	if err != nil {
 		return wrap(err, "Request create failed")
 	}
 
-	resp, err := q.getClient().Do(req)
+	resp, err := q.do("complete", q.getClient(), req)
 
 	if err != nil {
+		q.countError("complete", err)
 		return wrap(err, "Sending DELETE createRequest failed")
 	}
 
 	defer resp.Body.Close()
 
-	return handleStatusCode(resp)
+	err = handleStatusCode(resp)
+	q.logResponse("complete", msg, resp.StatusCode, err)
+
+	if err != nil {
+		q.countError("complete", err)
+		return err
+	}
+
+	q.countMessages(counterComplete, 1)
+
+	if q.Tracker != nil {
+		q.Tracker.Settled(msg, Completed)
+	}
+
+	q.unwatchLock(msg)
+
+	q.markProcessed(msg)
+
+	return nil
 }
 
 const azureQueueURL = "https://%s.servicebus.windows.net:443/%s/"
 
 func (q *QueueClient) createRequest(path string, method string) (*http.Request, error) {
-	url := fmt.Sprintf(azureQueueURL, q.Namespace, q.QueueName) + path
+	return q.createRequestWithBody(path, method, nil)
+}
+
+func (q *QueueClient) createRequestWithBody(path string, method string, body []byte) (*http.Request, error) {
+	if err := q.checkFIPS(); err != nil {
+		return nil, err
+	}
+
+	url, resource := q.requestURL(path)
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
 
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequest(method, url, reader)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Authorization", q.makeAuthHeader(url, time.Now()))
+	for k, v := range q.Headers {
+		req.Header.Set(k, v)
+	}
+
+	// ask for the body as stored, otherwise the transport requests gzip and
+	// transparently decompresses it, dropping the message's Content-Encoding
+	req.Header.Set("Accept-Encoding", "identity")
+	req.Header.Set("Authorization", q.makeAuthHeader(resource, time.Now()))
 	return req, nil
 }
 
 func (q *QueueClient) createRequestFromMessage(path string, method string, msg *Message) (*http.Request, error) {
-	url := fmt.Sprintf(azureQueueURL, q.Namespace, q.QueueName) + path
+	if err := q.checkFIPS(); err != nil {
+		return nil, err
+	}
+
+	url, resource := q.requestURL(path)
 
 	req, err := http.NewRequest(method, url, bytes.NewBuffer(msg.Body))
 	if err != nil {
 		return nil, err
 	}
 
-	for k, v := range msg.Properties {
+	for k, v := range q.Headers {
 		req.Header.Set(k, v)
 	}
 
+	for k, v := range msg.Properties {
+		req.Header.Set(k, quotePropertyValue(v))
+	}
+
 	// set BrokeredProperties header
 	b := brokerProperties{}
 	b.CopyFromMessage(msg)
+	if q.DerivePartitionKey && b.PartitionKey == "" {
+		b.PartitionKey = b.SessionId
+	}
 	bs, err := b.Marshal()
 	if err != nil {
 		return nil, err
@@ -246,12 +740,31 @@ func (q *QueueClient) createRequestFromMessage(path string, method string, msg *
 	// set Content-Type header
 	if msg.ContentType != "" {
 		req.Header.Set("Content-Type", msg.ContentType)
+	} else if q.DefaultContentType != "" {
+		req.Header.Set("Content-Type", q.DefaultContentType)
+	}
+
+	// set Content-Encoding header, the body is sent as is
+	if msg.ContentEncoding != "" {
+		req.Header.Set(headerContentEncoding, msg.ContentEncoding)
 	}
 
-	req.Header.Set("Authorization", q.makeAuthHeader(url, time.Now()))
+	req.Header.Set("Authorization", q.makeAuthHeader(resource, time.Now()))
 	return req, nil
 }
 
+// Returns the URL to send a request to and the Service Bus resource URI to sign it for.
+// They differ only when the client is fronted by a gateway.
+func (q *QueueClient) requestURL(path string) (string, string) {
+	resource := fmt.Sprintf(azureQueueURL, q.Namespace, q.QueueName) + path
+
+	if q.GatewayURL == "" {
+		return resource, resource
+	}
+
+	return strings.TrimSuffix(q.GatewayURL, "/") + "/" + q.QueueName + "/" + path, resource
+}
+
 func (q *QueueClient) getClient() HttpClient {
 
 	if httpClientOverride != nil {
@@ -266,12 +779,49 @@ func (q *QueueClient) getClient() HttpClient {
 	defer q.mu.Unlock()
 
 	if q.httpClient == nil {
-		q.httpClient = &http.Client{}
+		client := &http.Client{Timeout: q.HttpTimeout}
+		if q.DialContext != nil || q.Resolver != nil {
+			client.Transport = q.transport()
+		}
+		q.httpClient = client
 	}
 
 	return q.httpClient
 }
 
+// Returns a transport like http.DefaultTransport dialing with DialContext or Resolver.
+func (q *QueueClient) transport() *http.Transport {
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if q.DialContext != nil {
+		t.DialContext = q.DialContext
+	} else {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Resolver: q.Resolver}
+		t.DialContext = dialer.DialContext
+	}
+
+	return t
+}
+
+// Minimal time an HTTP client timeout must leave on top of the server-side long poll timeout.
+const LongPollSlack = 5 * time.Second
+
+// Verifies that the HTTP client won't give up on a long poll before the server answers it.
+func validateTimeout(client HttpClient, timeout int) error {
+
+	c, ok := client.(*http.Client)
+	if !ok || c.Timeout == 0 {
+		return nil
+	}
+
+	if c.Timeout <= time.Duration(timeout)*time.Second+LongPollSlack {
+		return fmt.Errorf("HTTP client timeout %v must exceed the receive timeout of %vs by more than %v", c.Timeout, timeout, LongPollSlack)
+	}
+
+	return nil
+}
+
 // Creates an authenticaiton header with Shared Access Signature token.
 //
 // For more information see: https://docs.microsoft.com/en-us/azure/service-bus-messaging/service-bus-sas
@@ -282,28 +832,41 @@ func (q *QueueClient) makeAuthHeader(uri string, from time.Time) string {
 	epoch := from.Add(expireInSeconds * time.Second).Round(time.Second).Unix()
 	expiry := strconv.Itoa(int(epoch))
 
-	// as per https://docs.microsoft.com/en-us/azure/service-bus-messaging/service-bus-sas
-	encodedUri := strings.ToLower(url.QueryEscape(uri))
-	sig := q.makeSignatureString(encodedUri + "\n" + expiry)
-	return fmt.Sprintf("SharedAccessSignature sig=%s&se=%s&skn=%s&sr=%s", sig, expiry, q.KeyName, encodedUri)
+	encodedUri := url.QueryEscape(uri)
+	if q.SasUriCasing == UriLowerCase {
+		// as per https://docs.microsoft.com/en-us/azure/service-bus-messaging/service-bus-sas
+		encodedUri = strings.ToLower(encodedUri)
+	}
+	keyName, keyValue := q.credentials()
+	sig := signatureString(keyValue, encodedUri + "\n" + expiry)
+	return fmt.Sprintf("SharedAccessSignature sig=%s&se=%s&skn=%s&sr=%s", sig, expiry, keyName, encodedUri)
 }
 
 // Returns SHA-256 hash of the scope of the token with a CRLF appended and an expiry time.
 func (q *QueueClient) makeSignatureString(s string) string {
+	_, keyValue := q.credentials()
+	return signatureString(keyValue, s)
+}
+
+func signatureString(key string, s string) string {
 	// as per https://docs.microsoft.com/en-us/azure/service-bus-messaging/service-bus-sas
-	h := hmac.New(sha256.New, []byte(q.KeyValue))
+	h := hmac.New(sha256.New, []byte(key))
 	h.Write([]byte(s))
 	encodedSig := base64.StdEncoding.EncodeToString(h.Sum(nil))
 	return url.QueryEscape(encodedSig)
 }
 
+// Number of bytes of error response bodies which are read.
+const maxErrorBodySize = 64 * 1024
+
 func handleStatusCode(resp *http.Response) error {
 
 	if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated {
 		return nil
 	}
 
-	body, _ := ioutil.ReadAll(resp.Body)
+	// error bodies are only diagnostics, don't let a misbehaving proxy exhaust memory
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
 
 	switch resp.StatusCode {
 	case 204:
@@ -312,26 +875,49 @@ func handleStatusCode(resp *http.Response) error {
 		return BadRequestError{400, string(body)}
 	case 401:
 		return NotAuthorizedError{401, string(body)}
+	case 403:
+		return ForbiddenError{403, string(body)}
 	case 404:
 		return MessageDontExistError{404, string(body)}
+	case 408:
+		return RequestTimeoutError{408, string(body)}
 	case 410:
 		return QueueDontExistError{410, string(body)}
 	case 500:
 		return InternalError{500, string(body)}
 	}
 
-	return fmt.Errorf("Unknown status %v with body %v", resp.StatusCode, string(body))
+	return UnknownStatusError{resp.StatusCode, string(body)}
 }
 
-func parseMessage(resp *http.Response) (*Message, error) {
+func parseSendResponse(resp *http.Response) *SendResponse {
+
+	r := &SendResponse{
+		StatusCode: resp.StatusCode,
+		RequestId:  resp.Header.Get(headerRequestId),
+	}
+
+	if t, err := time.Parse(Rfc2616Time, resp.Header.Get(headerDate)); err == nil {
+		r.Date = t
+	}
 
-	logger.Debug("Response StatusCode ", resp.StatusCode)
-	logger.Debug("Response Status ", resp.Status)
-	logger.Debug("Response Header ", resp.Header)
-	logger.Debug("Response ContentLength ", resp.ContentLength)
+	if properties := resp.Header.Get(headerBrokerProperties); len(properties) > 0 {
+		p := brokerProperties{}
+		if err := json.Unmarshal([]byte(properties), &p); err == nil {
+			r.SequenceNumber = p.SequenceNumber
+		}
+	}
+
+	return r
+}
+
+// Parses a received message. If the body exceeds limit, the message is returned
+// without its body along with ResponseTooLargeError.
+func parseMessage(resp *http.Response, limit int64) (*Message, error) {
 
 	m := Message{
-		Properties: Properties{},
+		Properties:       Properties{},
+		SystemProperties: Properties{},
 	}
 
 	parseHeaders(&m, resp)
@@ -342,7 +928,11 @@ func parseMessage(resp *http.Response) (*Message, error) {
 		parseBrokerProperties(&m, brokerProperties)
 	}
 
-	value, err := ioutil.ReadAll(resp.Body)
+	value, err := readBody(resp.Body, limit)
+
+	if _, ok := err.(ResponseTooLargeError); ok {
+		return &m, err
+	}
 
 	if err != nil {
 		return nil, wrap(err, "Error reading message body")
@@ -353,6 +943,26 @@ func parseMessage(resp *http.Response) (*Message, error) {
 	return &m, nil
 }
 
+// Reads a response body, failing with ResponseTooLargeError if it exceeds limit bytes.
+// A limit of zero reads the body whole.
+func readBody(r io.Reader, limit int64) ([]byte, error) {
+
+	if limit <= 0 {
+		return ioutil.ReadAll(r)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > limit {
+		return nil, ResponseTooLargeError{Limit: limit}
+	}
+
+	return body, nil
+}
+
 func parseHeaders(m *Message, resp *http.Response) {
 	for k, v := range resp.Header {
 
@@ -366,6 +976,11 @@ func parseHeaders(m *Message, resp *http.Response) {
 				m.ContentType = v[0]
 				continue
 			}
+		case headerContentEncoding:
+			{
+				m.ContentEncoding = v[0]
+				continue
+			}
 		case headerDate:
 			{
 				if t, err := time.Parse(Rfc2616Time, v[0]); err == nil {
@@ -375,13 +990,42 @@ func parseHeaders(m *Message, resp *http.Response) {
 			}
 		default:
 			{
+				if isSystemHeader(k) {
+					if m.SystemProperties == nil {
+						m.SystemProperties = Properties{}
+					}
+					m.SystemProperties.Set(k, strings.Join(v, ", "))
+					continue
+				}
+
 				// azure returns customer headers quoted
-				m.Properties.Set(k, strings.Trim(v[0], "\""))
+				values := make([]string, len(v))
+				for i := range v {
+					values[i] = unquotePropertyValue(v[i])
+				}
+				m.setPropertyValues(k, values)
 			}
 		}
 	}
 }
 
+// Reports whether the header is a system annotation rather than a custom property.
+func isSystemHeader(key string) bool {
+	key = strings.ToLower(key)
+	return strings.HasPrefix(key, "x-ms-") || strings.HasPrefix(key, "x-opt-")
+}
+
+// Logs the BrokerProperties fields skipped while parsing the received message,
+// each only the first time the client skips it.
+func (q *QueueClient) logSkippedProperties(msg *Message) {
+
+	for _, name := range msg.SkippedBrokerProperties {
+		if _, logged := q.loggedSkipped.LoadOrStore(name, true); !logged {
+			logger.DebugFields("BrokerProperties field "+name+" skipped, its value has an unexpected type", q.logFields("receive", msg))
+		}
+	}
+}
+
 func parseBrokerProperties(m *Message, properties string) {
 
 	logger.Debug("Response BrokerProperties ", properties)
@@ -392,6 +1036,7 @@ func parseBrokerProperties(m *Message, properties string) {
 		return
 	}
 
+	m.SkippedBrokerProperties = p.skipped
 	m.Id = p.MessageId
 	m.SessionId = p.SessionId
 	m.LockToken = p.LockToken
@@ -460,6 +1105,114 @@ type brokerProperties struct {
 
 	// Res
 	SequenceNumber int64 `json:"SequenceNumber,omitempty"`
+
+	// Names of fields which were skipped while parsing.
+	skipped []string
+}
+
+// Parses broker properties tolerating variations seen in the wild: numbers encoded
+// as strings or with fractions, e.g. the maximal TimeToLive, strings encoded as numbers,
+// and unknown or missing fields. Fields which still can't be parsed are skipped and
+// recorded rather than failing the whole parse and dropping the LockToken.
+func (p *brokerProperties) UnmarshalJSON(data []byte) error {
+
+	raw := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	texts := map[string]*string{
+		"messageid":               &p.MessageId,
+		"label":                   &p.Label,
+		"correlationid":           &p.CorrelationId,
+		"sessionid":               &p.SessionId,
+		"to":                      &p.To,
+		"replyto":                 &p.ReplyTo,
+		"scheduledenqueuetimeutc": &p.ScheduledEnqueueTimeUtc,
+		"replytosessionid":        &p.ReplyToSessionId,
+		"partitionkey":            &p.PartitionKey,
+		"locktoken":               &p.LockToken,
+		"lockeduntilutc":          &p.LockedUntilUtc,
+	}
+
+	var timeToLive, deliveryCount int64
+	numbers := map[string]*int64{
+		"timetolive":     &timeToLive,
+		"deliverycount":  &deliveryCount,
+		"sequencenumber": &p.SequenceNumber,
+	}
+
+	for name, value := range raw {
+		key := strings.ToLower(name)
+		ok := true
+
+		if text, known := texts[key]; known {
+			*text, ok = parseText(value)
+		} else if number, known := numbers[key]; known {
+			*number, ok = parseNumber(value)
+		}
+
+		if !ok {
+			p.skipped = append(p.skipped, name)
+		}
+	}
+
+	sort.Strings(p.skipped)
+	p.TimeToLive = int(timeToLive)
+	p.DeliveryCount = int(deliveryCount)
+	return nil
+}
+
+// Parses a JSON string, or the literal text of a number or boolean.
+func parseText(value json.RawMessage) (string, bool) {
+
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s, true
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(value, &v); err != nil {
+		return "", false
+	}
+
+	switch v.(type) {
+	case float64, bool:
+		return string(value), true
+	case nil:
+		return "", true
+	}
+	return "", false
+}
+
+// Parses a JSON number, possibly quoted or with a fraction which is truncated.
+func parseNumber(value json.RawMessage) (int64, bool) {
+
+	text := string(value)
+
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		text = s
+	} else if text == "null" {
+		return 0, true
+	}
+
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n, true
+	}
+
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, false
+	}
+
+	if f >= math.MaxInt64 {
+		return math.MaxInt64, true
+	}
+	if f <= math.MinInt64 {
+		return math.MinInt64, true
+	}
+	return int64(f), true
 }
 
 func (p *brokerProperties) CopyFromMessage(msg *Message) {
@@ -473,9 +1226,9 @@ func (p *brokerProperties) CopyFromMessage(msg *Message) {
 	p.ReplyToSessionId = msg.ReplyToSessionId
 	p.PartitionKey = msg.PartitionKey
 
-	defaultTime := time.Time{}
-	if msg.ScheduledEnqueueTimeUtc != defaultTime {
-		p.ScheduledEnqueueTimeUtc = msg.ScheduledEnqueueTimeUtc.Format(Rfc2616Time)
+	// the service expects GMT, a time in another location would name its own zone
+	if !msg.ScheduledEnqueueTimeUtc.IsZero() {
+		p.ScheduledEnqueueTimeUtc = msg.ScheduledEnqueueTimeUtc.UTC().Format(http.TimeFormat)
 	}
 }
 
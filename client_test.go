@@ -2,12 +2,19 @@ package queue
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -61,11 +68,30 @@ var errorTestCases = []errorCase{
 	errorCase{204, reflect.TypeOf(NoMessagesAvailableError{}), "204"},
 	errorCase{400, reflect.TypeOf(BadRequestError{}), "400"},
 	errorCase{401, reflect.TypeOf(NotAuthorizedError{}), "401"},
+	errorCase{403, reflect.TypeOf(ForbiddenError{}), "403"},
 	errorCase{404, reflect.TypeOf(MessageDontExistError{}), "404"},
+	errorCase{408, reflect.TypeOf(RequestTimeoutError{}), "408"},
 	errorCase{410, reflect.TypeOf(QueueDontExistError{}), "410"},
 	errorCase{500, reflect.TypeOf(InternalError{}), "500"},
 }
 
+// HttpClient stub returning canned responses.
+type fakeHttpClient func(req *http.Request) (*http.Response, error)
+
+func (f fakeHttpClient) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func respondWith(code int, body string) fakeHttpClient {
+	return func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: code,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+		}, nil
+	}
+}
+
 func TestMain(m *testing.M) {
 	SetDebugLogger(nil)
 
@@ -113,12 +139,215 @@ func Test_createRequestFromMessage(t *testing.T) {
 	}
 
 	for k, _ := range testMsg.Properties {
-		if req.Header.Get(k) != testMsg.Properties[k] {
-			t.Fatalf("Expected header %s value %s but got %s", k, testMsg.Properties[k], req.Header.Get(k))
+		if req.Header.Get(k) != "\""+testMsg.Properties[k]+"\"" {
+			t.Fatalf("Expected header %s value \"%s\" but got %s", k, testMsg.Properties[k], req.Header.Get(k))
 		}
 	}
 }
 
+func Test_GatewayURL(t *testing.T) {
+
+	cli := &QueueClient{
+		Namespace:  "test",
+		KeyName:    "key",
+		KeyValue:   "keyvalue",
+		QueueName:  "test",
+		GatewayURL: "https://contoso.azure-api.net/servicebus/",
+	}
+
+	req, err := cli.createRequest("messages/head?timeout=0", "POST")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedURL := "https://contoso.azure-api.net/servicebus/test/messages/head?timeout=0"
+	if req.URL.String() != expectedURL {
+		t.Fatalf("Expected URL %s but got %s", expectedURL, req.URL.String())
+	}
+
+	expectedScope := "sr=https%3a%2f%2ftest.servicebus.windows.net%3a443%2ftest%2fmessages%2fhead%3ftimeout%3d0"
+	if auth := req.Header.Get("Authorization"); !strings.HasSuffix(auth, expectedScope) {
+		t.Fatalf("Expected token scoped to the Service Bus resource but got %s", auth)
+	}
+}
+
+func Test_Headers(t *testing.T) {
+
+	cli := &QueueClient{
+		Namespace: "test",
+		QueueName: "test",
+		Headers:   map[string]string{"Ocp-Apim-Subscription-Key": "abc", "Prop1": "Default"},
+	}
+
+	req, err := cli.createRequest("messages/head", "POST")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Header.Get("Ocp-Apim-Subscription-Key") != "abc" {
+		t.Fatalf("Expected header %s value %s but got %s", "Ocp-Apim-Subscription-Key", "abc", req.Header.Get("Ocp-Apim-Subscription-Key"))
+	}
+
+	req, err = cli.createRequestFromMessage("messages/", "POST", &testMsg)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Header.Get("Ocp-Apim-Subscription-Key") != "abc" {
+		t.Fatalf("Expected header %s value %s but got %s", "Ocp-Apim-Subscription-Key", "abc", req.Header.Get("Ocp-Apim-Subscription-Key"))
+	}
+
+	if req.Header.Get("Prop1") != "\"Value1\"" {
+		t.Fatalf("Expected message property to take precedence but got %s", req.Header.Get("Prop1"))
+	}
+}
+
+func Test_DerivePartitionKey(t *testing.T) {
+
+	tests := []struct {
+		derive       bool
+		sessionId    string
+		partitionKey string
+		expected     string
+	}{
+		{false, "session", "", ""},
+		{true, "session", "", "session"},
+		{true, "session", "partition", "partition"},
+		{true, "", "", ""},
+	}
+
+	for _, test := range tests {
+		cli := &QueueClient{Namespace: "test", QueueName: "test", DerivePartitionKey: test.derive}
+
+		msg := NewMessage([]byte("hello"))
+		msg.SessionId = test.sessionId
+		msg.PartitionKey = test.partitionKey
+
+		req, err := cli.createRequestFromMessage("messages/", "POST", msg)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := brokerProperties{}
+		if err := json.Unmarshal([]byte(req.Header.Get(headerBrokerProperties)), &p); err != nil {
+			t.Fatal(err)
+		}
+
+		if p.PartitionKey != test.expected {
+			t.Fatalf("Expected PartitionKey %s but got %s", test.expected, p.PartitionKey)
+		}
+
+		if msg.PartitionKey != test.partitionKey {
+			t.Fatal("Expected message not to be modified")
+		}
+	}
+}
+
+func Test_DefaultContentType(t *testing.T) {
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test", DefaultContentType: "application/json"}
+
+	tests := []struct {
+		contentType string
+		expected    string
+	}{
+		{"", "application/json"},
+		{"text/plain", "text/plain"},
+	}
+
+	for _, test := range tests {
+		msg := NewMessage([]byte("hello"))
+		msg.ContentType = test.contentType
+
+		req, err := cli.createRequestFromMessage("messages/", "POST", msg)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if req.Header.Get("Content-Type") != test.expected {
+			t.Fatalf("Expected Content-Type %s but got %s", test.expected, req.Header.Get("Content-Type"))
+		}
+	}
+}
+
+func Test_ContentEncoding(t *testing.T) {
+
+	msg := NewMessage([]byte("hello"))
+	msg.ContentEncoding = "gzip"
+
+	req, err := q.createRequestFromMessage("messages/", "POST", msg)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected Content-Encoding %s but got %s", "gzip", req.Header.Get("Content-Encoding"))
+	}
+
+	resp := &http.Response{
+		Header: http.Header{"Content-Encoding": []string{"gzip"}},
+	}
+
+	received := &Message{Properties: Properties{}}
+	parseHeaders(received, resp)
+
+	if received.ContentEncoding != "gzip" {
+		t.Fatalf("Expected Content-Encoding %s but got %s", "gzip", received.ContentEncoding)
+	}
+
+	if received.Properties.Get("Content-Encoding") != "" {
+		t.Fatal("Expected Content-Encoding not to be a custom property")
+	}
+}
+
+func Test_ContentEncoding_overHttp(t *testing.T) {
+
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	w.Write([]byte("hello"))
+	w.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Set("BrokerProperties", `{"MessageId":"1","LockToken":"2"}`)
+		w.WriteHeader(201)
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	override := httpClientOverride
+	SetHttpClient(nil)
+	defer SetHttpClient(override)
+
+	cli := QueueClient{
+		Namespace:  "test",
+		KeyName:    "key",
+		KeyValue:   "value",
+		QueueName:  "queue",
+		GatewayURL: server.URL,
+	}
+
+	msg, err := cli.GetMessage()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.ContentEncoding != "gzip" {
+		t.Fatalf("Expected Content-Encoding %s but got %s", "gzip", msg.ContentEncoding)
+	}
+
+	if !bytes.Equal(msg.Body, compressed.Bytes()) {
+		t.Fatalf("Expected body %v but got %v", compressed.Bytes(), msg.Body)
+	}
+}
+
 func Test_parseMessage(t *testing.T) {
 
 	resp := http.Response{
@@ -130,7 +359,7 @@ func Test_parseMessage(t *testing.T) {
 		Body: ioutil.NopCloser(bytes.NewBufferString("Hello World")),
 	}
 
-	msg, err := parseMessage(&resp)
+	msg, err := parseMessage(&resp, 0)
 
 	if err != nil {
 		t.Error(err)
@@ -139,6 +368,37 @@ func Test_parseMessage(t *testing.T) {
 	compareMsg(t, &testMsg, msg, false)
 }
 
+func Test_MaxResponseBodySize(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	var unlocked bool
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "PUT" {
+			unlocked = true
+			return respondWith(200, "")(req)
+		}
+		resp, _ := respondWith(201, strings.Repeat("x", 11))(req)
+		resp.Header.Set("BrokerProperties", `{"MessageId":"abc","LockToken":"token"}`)
+		return resp, nil
+	}))
+
+	cli := &QueueClient{Namespace: "test", KeyName: "key", KeyValue: "keyvalue", QueueName: "test", MaxResponseBodySize: 10}
+
+	if _, err := cli.GetMessage(); err != (ResponseTooLargeError{Limit: 10}) {
+		t.Fatalf("Expected ResponseTooLargeError but got %v", err)
+	}
+
+	if !unlocked {
+		t.Fatalf("Expected oversized message to be unlocked")
+	}
+
+	cli.MaxResponseBodySize = 11
+	if msg, err := cli.GetMessage(); err != nil || len(msg.Body) != 11 {
+		t.Fatalf("Expected body within the limit to be read but got %v", err)
+	}
+}
+
 func Test_parseHeaders(t *testing.T) {
 
 	expectedProps := Properties{
@@ -163,6 +423,31 @@ func Test_parseHeaders(t *testing.T) {
 	compareProperties(t, expectedProps, msg.Properties)
 }
 
+func Test_parseHeaders_systemProperties(t *testing.T) {
+
+	resp := &http.Response{
+		Header: map[string][]string{
+			"Prop1":               []string{"\"Value1\""},
+			"X-Ms-Request-Id":     []string{"abc"},
+			"X-Opt-Enqueued-Time": []string{"123"},
+		},
+	}
+
+	msg := &Message{
+		Properties:       Properties{},
+		SystemProperties: Properties{},
+	}
+
+	parseHeaders(msg, resp)
+
+	compareProperties(t, Properties{"Prop1": "Value1"}, msg.Properties)
+	compareProperties(t, Properties{"X-Ms-Request-Id": "abc", "X-Opt-Enqueued-Time": "123"}, msg.SystemProperties)
+
+	if len(msg.Properties) != 1 {
+		t.Fatalf("Expected system annotations not to be mixed into Properties but got %v", msg.Properties)
+	}
+}
+
 func Test_parseBrokerProperties(t *testing.T) {
 
 	msg := &Message{}
@@ -173,6 +458,53 @@ func Test_parseBrokerProperties(t *testing.T) {
 	compareMsg(t, &testMsg, msg, true)
 }
 
+func Test_parseBrokerProperties_tolerant(t *testing.T) {
+
+	msg := &Message{}
+	parseBrokerProperties(msg, `{"MessageId":12345,"LockToken":"token","DeliveryCount":"2","SequenceNumber":"7",`+
+		`"TimeToLive":922337203685.47754,"State":"Active","EnqueuedSequenceNumber":7,"Label":{"nested":true},"locktoken":"token"}`)
+
+	if msg.Id != "12345" || msg.LockToken != "token" || msg.DeliveryCount != 2 || msg.SequenceNumber != 7 || msg.TimeToLive != 922337203685 {
+		t.Fatalf("Expected tolerant parse but got %+v", msg)
+	}
+
+	if !reflect.DeepEqual(msg.SkippedBrokerProperties, []string{"Label"}) {
+		t.Fatalf("Expected only Label to be recorded as skipped but got %q", msg.SkippedBrokerProperties)
+	}
+}
+
+func Test_logSkippedProperties(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	defer SetDebugLogger(nil)
+
+	var logged []string
+	SetDebugLogger(func(v ...interface{}) {
+		if line := fmt.Sprint(v...); strings.Contains(line, "skipped") {
+			logged = append(logged, line)
+		}
+	})
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		resp, _ := respondWith(201, "hello")(req)
+		resp.Header.Set(headerBrokerProperties, `{"MessageId":"abc","LockToken":"token","Label":{"nested":true}}`)
+		return resp, nil
+	}))
+
+	first := QueueClient{Namespace: "test", KeyName: "key", KeyValue: "keyvalue", QueueName: "first"}
+	second := QueueClient{Namespace: "test", KeyName: "key", KeyValue: "keyvalue", QueueName: "second"}
+
+	for _, cli := range []*QueueClient{&first, &first, &second} {
+		if _, err := cli.GetMessage(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(logged) != 2 || !strings.Contains(logged[0], "Label") || !strings.Contains(logged[1], "second") {
+		t.Fatalf("Expected skipped field to be logged once per client but got %q", logged)
+	}
+}
+
 func Test_authentication(t *testing.T) {
 
 	from := time.Date(2018, 1, 1, 1, 1, 1, 0, loc)
@@ -192,6 +524,87 @@ func Test_authentication(t *testing.T) {
 	}
 }
 
+func Test_SendMessageWithResponse(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		resp, _ := respondWith(201, "")(req)
+		resp.Header.Set("Date", "Sun, 06 Nov 1994 08:49:37 GMT")
+		resp.Header.Set("X-Ms-Request-Id", "abc")
+		resp.Header.Set("BrokerProperties", `{"SequenceNumber":12345}`)
+		return resp, nil
+	}))
+
+	r, err := q.SendMessageWithResponse(NewMessage([]byte("hello")))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := SendResponse{
+		StatusCode:     201,
+		RequestId:      "abc",
+		Date:           time.Date(1994, 11, 6, 8, 49, 37, 0, loc),
+		SequenceNumber: 12345,
+	}
+
+	if r.StatusCode != expected.StatusCode || r.RequestId != expected.RequestId || !r.Date.Equal(expected.Date) || r.SequenceNumber != expected.SequenceNumber {
+		t.Fatalf("Expected response %+v but got %+v", expected, *r)
+	}
+}
+
+func Test_SendAfter(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	var sent brokerProperties
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		json.Unmarshal([]byte(req.Header.Get("BrokerProperties")), &sent)
+		return respondWith(201, "")(req)
+	}))
+
+	msg := NewMessage([]byte("hello"))
+	if err := q.SendAfter(msg, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	scheduled, err := time.Parse(Rfc2616Time, sent.ScheduledEnqueueTimeUtc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if delay := time.Until(scheduled); delay < 59*time.Minute || delay > time.Hour {
+		t.Fatalf("Expected message scheduled in an hour but got %v", sent.ScheduledEnqueueTimeUtc)
+	}
+
+	if !msg.ScheduledEnqueueTimeUtc.IsZero() {
+		t.Fatalf("Expected message to be unchanged")
+	}
+}
+
+func Test_authentication_uriCasing(t *testing.T) {
+
+	from := time.Date(2018, 1, 1, 1, 1, 1, 0, loc)
+	url := "https://test.servicebus.windows.net:443/MyQueue/"
+
+	tests := []struct {
+		casing   UriCasing
+		expected string
+	}{
+		{UriLowerCase, "SharedAccessSignature sig=H2s9BixTAbtZ4xUxjP2pY9u07kG2ntHWt0ep5zyjiQE%3D&se=1514768761&skn=key&sr=https%3a%2f%2ftest.servicebus.windows.net%3a443%2fmyqueue%2f"},
+		{UriPreserveCase, "SharedAccessSignature sig=y4xAiQATZ%2Bj8hVl7WKPdF3eR3xosm5Qs2okcJJ7%2FVTI%3D&se=1514768761&skn=key&sr=https%3A%2F%2Ftest.servicebus.windows.net%3A443%2FMyQueue%2F"},
+	}
+
+	for _, test := range tests {
+		cli := &QueueClient{KeyName: "key", KeyValue: "keyvalue", SasUriCasing: test.casing}
+
+		if header := cli.makeAuthHeader(url, from); header != test.expected {
+			t.Fatalf("Expected header %s but got %s", test.expected, header)
+		}
+	}
+}
+
 func Test_handleStatusCode_error(t *testing.T) {
 	for _, tCase := range errorTestCases {
 
@@ -266,6 +679,70 @@ func Test_getClient_default(t *testing.T) {
 	}
 }
 
+func Test_getClient_httpTimeout(t *testing.T) {
+
+	SetHttpClient(nil)
+
+	cli := &QueueClient{HttpTimeout: time.Minute}
+
+	if c, ok := cli.getClient().(*http.Client); !ok || c.Timeout != time.Minute {
+		t.Fatalf("Expected default client with timeout %v", time.Minute)
+	}
+}
+
+func Test_getClient_dialContext(t *testing.T) {
+
+	SetHttpClient(nil)
+
+	var dialed string
+	cli := &QueueClient{Namespace: "test", KeyName: "key", KeyValue: "keyvalue", QueueName: "test"}
+	cli.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = addr
+		return nil, errors.New("refused")
+	}
+
+	if err := cli.SendMessage(NewMessage([]byte("hello"))); err == nil {
+		t.Fatalf("Expected dial error")
+	}
+
+	if dialed != "test.servicebus.windows.net:443" {
+		t.Fatalf("Expected custom dialer to be used but got %q", dialed)
+	}
+}
+
+func Test_validateTimeout(t *testing.T) {
+
+	tests := []struct {
+		client  HttpClient
+		timeout int
+		valid   bool
+	}{
+		{&http.Client{}, 60, true},
+		{&http.Client{Timeout: 70 * time.Second}, 60, true},
+		{&http.Client{Timeout: 65 * time.Second}, 60, false},
+		{&http.Client{Timeout: 30 * time.Second}, 60, false},
+		{respondWith(200, ""), 60, true},
+	}
+
+	for _, test := range tests {
+		err := validateTimeout(test.client, test.timeout)
+
+		if (err == nil) != test.valid {
+			t.Fatalf("Expected valid %v for timeout %vs but got %v", test.valid, test.timeout, err)
+		}
+	}
+}
+
+func Test_GetMessage_invalidTimeout(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	SetHttpClient(&http.Client{Timeout: time.Second})
+
+	if _, err := q.getMessage(context.Background(), 60); err == nil {
+		t.Fatal("Expected error for HTTP timeout shorter than the receive timeout")
+	}
+}
+
 func Test_Properties(t *testing.T) {
 
 	tests := []struct {
@@ -290,6 +767,32 @@ func Test_Properties(t *testing.T) {
 	}
 }
 
+func Test_Message_Property(t *testing.T) {
+
+	msg := &Message{}
+	msg.SetProperty("myProp", "Value1")
+
+	if msg.Properties["Myprop"] != "Value1" {
+		t.Fatalf("Expected property to be stored as %s but got %v", "Myprop", msg.Properties)
+	}
+
+	msg.SetProperty("MYPROP", "Value2")
+
+	if len(msg.Properties) != 1 {
+		t.Fatalf("Expected one property but got %v", msg.Properties)
+	}
+
+	for _, key := range []string{"myProp", "Myprop", "MYPROP"} {
+		if msg.GetProperty(key) != "Value2" {
+			t.Fatalf("Expected property %s value %s but got %s", key, "Value2", msg.GetProperty(key))
+		}
+	}
+
+	if (&Message{}).GetProperty("myProp") != "" {
+		t.Fatal("Expected empty value for a message without properties")
+	}
+}
+
 func Test_brokerProperties_Marshal(t *testing.T) {
 
 	p := brokerProperties{}
@@ -317,6 +820,19 @@ func Test_brokerProperties_Marshal(t *testing.T) {
 	}
 }
 
+func Test_brokerProperties_scheduledEnqueueTimeUtc(t *testing.T) {
+
+	auckland := time.FixedZone("NZDT", 13*60*60)
+
+	p := brokerProperties{}
+	p.CopyFromMessage(&Message{ScheduledEnqueueTimeUtc: time.Date(2018, 2, 22, 10, 3, 56, 0, auckland)})
+
+	expected := "Wed, 21 Feb 2018 21:03:56 GMT"
+	if p.ScheduledEnqueueTimeUtc != expected {
+		t.Fatalf("Expected ScheduledEnqueueTimeUtc %s but got %s", expected, p.ScheduledEnqueueTimeUtc)
+	}
+}
+
 func Test_SendReceive(t *testing.T) {
 
 	t.Skip("Real parameters required")
@@ -436,3 +952,97 @@ func compareProperties(t *testing.T, expected Properties, actual Properties) {
 		}
 	}
 }
+
+func Test_ReceiveBatch_maxMessages(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	polls := 0
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		polls++
+		return respondWith(200, "hello")(req)
+	}))
+
+	msgs, err := q.ReceiveBatch(context.Background(), 3, time.Minute)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 3 || polls != 3 {
+		t.Fatalf("Expected 3 messages in 3 polls but got %v messages in %v polls", len(msgs), polls)
+	}
+}
+
+func Test_ReceiveBatch_invalidMaxMessages(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	polls := 0
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		polls++
+		return respondWith(200, "hello")(req)
+	}))
+
+	for _, maxMessages := range []int{0, -1} {
+		if _, err := q.ReceiveBatch(context.Background(), maxMessages, time.Minute); err == nil {
+			t.Fatalf("Expected error for maxMessages %v", maxMessages)
+		}
+	}
+
+	if polls != 0 {
+		t.Fatalf("Expected no polls but got %v", polls)
+	}
+}
+
+func Test_ReceiveBatch_maxWait(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	var timeouts []string
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		timeouts = append(timeouts, req.URL.Query().Get("timeout"))
+		if len(timeouts) == 1 {
+			return respondWith(200, "hello")(req)
+		}
+		return respondWith(204, "")(req)
+	}))
+
+	msgs, err := q.ReceiveBatch(context.Background(), 10, 0)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(msgs) != 1 {
+		t.Fatalf("Expected 1 message but got %v", len(msgs))
+	}
+
+	if !reflect.DeepEqual(timeouts, []string{"0", "0"}) {
+		t.Fatalf("Expected two polls with zero timeout but got %v", timeouts)
+	}
+}
+
+func Test_ReceiveBatch_error(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	polls := 0
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		polls++
+		if polls == 1 {
+			return respondWith(200, "hello")(req)
+		}
+		return respondWith(401, "")(req)
+	}))
+
+	msgs, err := q.ReceiveBatch(context.Background(), 10, time.Minute)
+
+	if _, ok := err.(NotAuthorizedError); !ok {
+		t.Fatalf("Expected error type NotAuthorizedError but got %v", err)
+	}
+
+	if len(msgs) != 1 {
+		t.Fatalf("Expected already received message to be returned but got %v messages", len(msgs))
+	}
+}
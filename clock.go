@@ -0,0 +1,49 @@
+package queue
+
+import "time"
+
+// Clock tells the current time, so lock expiry can be tested or corrected for clock skew.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+var clock Clock = systemClock{}
+
+// Sets the clock the package checks lock expiry against. Pass nil to use the system clock.
+func SetClock(c Clock) {
+	if c == nil {
+		c = systemClock{}
+	}
+	clock = c
+}
+
+// Returns how long the lock of a received message is still held according to the
+// given clock, or the package clock if nil. Returns 0 if the lock has expired or
+// the message has no LockedUntilUtc.
+func (m *Message) LockRemaining(c Clock) time.Duration {
+
+	if c == nil {
+		c = clock
+	}
+
+	if m.LockedUntilUtc.IsZero() {
+		return 0
+	}
+
+	if remaining := m.LockedUntilUtc.Sub(c.Now()); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Reports whether the lock of a received message has expired according to the package
+// clock, in which case settling it would fail. Messages without LockedUntilUtc aren't expired.
+func (m *Message) IsLockExpired() bool {
+	return !m.LockedUntilUtc.IsZero() && !clock.Now().Before(m.LockedUntilUtc)
+}
@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+type fixedClock time.Time
+
+func (c fixedClock) Now() time.Time {
+	return time.Time(c)
+}
+
+func Test_LockRemaining(t *testing.T) {
+
+	defer SetClock(nil)
+
+	now := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+	SetClock(fixedClock(now))
+
+	tests := []struct {
+		lockedUntil time.Time
+		remaining   time.Duration
+		expired     bool
+	}{
+		{now.Add(30 * time.Second), 30 * time.Second, false},
+		{now, 0, true},
+		{now.Add(-time.Second), 0, true},
+		{time.Time{}, 0, false},
+	}
+
+	for _, test := range tests {
+		msg := Message{LockedUntilUtc: test.lockedUntil}
+
+		if remaining := msg.LockRemaining(nil); remaining != test.remaining {
+			t.Fatalf("Expected %v remaining but got %v", test.remaining, remaining)
+		}
+
+		if msg.IsLockExpired() != test.expired {
+			t.Fatalf("Expected lock until %v expired %v", test.lockedUntil, test.expired)
+		}
+	}
+
+	msg := Message{LockedUntilUtc: now.Add(time.Minute)}
+	if remaining := msg.LockRemaining(fixedClock(now.Add(45 * time.Second))); remaining != 15*time.Second {
+		t.Fatalf("Expected the given clock to be used but got %v remaining", remaining)
+	}
+
+	msg.Id, msg.LockToken = "abc", "token"
+	SetClock(fixedClock(now.Add(2 * time.Minute)))
+	if err := q.DeleteMessage(&msg); err == nil {
+		t.Fatalf("Expected settling to check expiry against the package clock")
+	}
+}
@@ -0,0 +1,202 @@
+// Command azqload produces and consumes messages at configurable rates, sizes and
+// concurrency and reports latency percentiles, to validate namespace sizing and the
+// client's scaling behavior.
+//
+// The queue is configured through the SERVICEBUS_* environment variables:
+//
+//	SERVICEBUS_CONNECTION_STRING=... SERVICEBUS_QUEUE=load azqload -rate 200 -size 4096 -duration 1m
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	queue "github.com/g-rad/go-azurequeue"
+)
+
+// Custom property carrying the send time, measuring end-to-end latency.
+const sentAtProperty = "Azqload-Sent-At"
+
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int64
+}
+
+func (r *recorder) record(d time.Duration) {
+	r.mu.Lock()
+	r.latencies = append(r.latencies, d)
+	r.mu.Unlock()
+}
+
+func (r *recorder) fail() {
+	atomic.AddInt64(&r.errors, 1)
+}
+
+func (r *recorder) report(name string, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+
+	fmt.Printf("%-8s %8d ok %6d errors %8.1f/s  p50 %-10v p90 %-10v p99 %-10v max %v\n",
+		name, len(r.latencies), atomic.LoadInt64(&r.errors), float64(len(r.latencies))/elapsed.Seconds(),
+		percentile(r.latencies, 50), percentile(r.latencies, 90), percentile(r.latencies, 99), percentile(r.latencies, 100))
+}
+
+// Returns the p-th percentile of sorted latencies by the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := int(p/100*float64(len(sorted)) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1].Round(time.Microsecond)
+}
+
+func main() {
+
+	rate := flag.Float64("rate", 10, "messages sent per second across all producers, 0 to send as fast as possible")
+	size := flag.Int("size", 1024, "message body size in bytes")
+	producers := flag.Int("producers", 4, "number of concurrent producers")
+	consumers := flag.Int("consumers", 4, "number of concurrent consumers, 0 to only produce")
+	duration := flag.Duration("duration", 30*time.Second, "how long messages are produced")
+	drain := flag.Duration("drain", 10*time.Second, "how long consumers keep receiving after producing stops")
+	flag.Parse()
+
+	cli, err := queue.NewClientFromEnvironment()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	cli.Timeout = 1
+	queue.SetDebugLogger(nil)
+
+	if err := cli.WarmUp(context.Background()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	body := make([]byte, *size)
+	rand.Read(body)
+
+	var sends, receives, endToEnd recorder
+	start := time.Now()
+
+	produceCtx, stopProducing := context.WithTimeout(context.Background(), *duration)
+	defer stopProducing()
+
+	var interval time.Duration
+	if *rate > 0 {
+		interval = time.Duration(float64(time.Second) * float64(*producers) / *rate)
+	}
+
+	var producing sync.WaitGroup
+	for i := 0; i < *producers; i++ {
+		producing.Add(1)
+		go func() {
+			defer producing.Done()
+			produce(produceCtx, cli, body, interval, &sends)
+		}()
+	}
+
+	consumeCtx, stopConsuming := context.WithCancel(context.Background())
+	var consuming sync.WaitGroup
+	for i := 0; i < *consumers; i++ {
+		consuming.Add(1)
+		go func() {
+			defer consuming.Done()
+			consume(consumeCtx, cli, &receives, &endToEnd)
+		}()
+	}
+
+	producing.Wait()
+	produced := time.Since(start)
+
+	if *consumers > 0 {
+		time.Sleep(*drain)
+	}
+	stopConsuming()
+	consuming.Wait()
+	consumed := time.Since(start)
+
+	sends.report("send", produced)
+	if *consumers > 0 {
+		receives.report("receive", consumed)
+		endToEnd.report("e2e", consumed)
+	}
+}
+
+func produce(ctx context.Context, cli *queue.QueueClient, body []byte, interval time.Duration, sends *recorder) {
+
+	var ticker *time.Ticker
+	if interval > 0 {
+		ticker = time.NewTicker(interval)
+		defer ticker.Stop()
+	}
+
+	for ctx.Err() == nil {
+		if ticker != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+
+		msg := queue.NewMessage(body)
+		sentAt := time.Now()
+		msg.SetProperty(sentAtProperty, strconv.FormatInt(sentAt.UnixNano(), 10))
+
+		if err := cli.SendMessage(msg); err != nil {
+			sends.fail()
+			continue
+		}
+		sends.record(time.Since(sentAt))
+	}
+}
+
+func consume(ctx context.Context, cli *queue.QueueClient, receives *recorder, endToEnd *recorder) {
+
+	for ctx.Err() == nil {
+		started := time.Now()
+		msg, err := cli.GetMessage()
+
+		var empty queue.NoMessagesAvailableError
+		if errors.As(err, &empty) {
+			continue
+		}
+		if err != nil {
+			receives.fail()
+			continue
+		}
+
+		if err := cli.DeleteMessage(msg); err != nil {
+			receives.fail()
+			continue
+		}
+
+		now := time.Now()
+		receives.record(now.Sub(started))
+
+		if sentAt, err := strconv.ParseInt(msg.GetProperty(sentAtProperty), 10, 64); err == nil {
+			endToEnd.record(now.Sub(time.Unix(0, sentAt)))
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_percentile(t *testing.T) {
+
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	tests := []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{50, 50 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+		{0, time.Millisecond},
+	}
+
+	for _, test := range tests {
+		if actual := percentile(sorted, test.p); actual != test.expected {
+			t.Fatalf("Expected p%v %v but got %v", test.p, test.expected, actual)
+		}
+	}
+
+	if percentile(nil, 50) != 0 {
+		t.Fatalf("Expected 0 for no latencies")
+	}
+}
@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"reflect"
+)
+
+// Codec decodes message bodies of a Content-Type into typed values.
+type Codec interface {
+	Decode(body []byte) (interface{}, error)
+}
+
+// Adapts a function to the Codec interface.
+type CodecFunc func(body []byte) (interface{}, error)
+
+func (f CodecFunc) Decode(body []byte) (interface{}, error) {
+	return f(body)
+}
+
+// Maps media types such as "application/json" to the codecs decoding them.
+// Parameters of the Content-Type, e.g. charset, are ignored for the lookup.
+type CodecRegistry map[string]Codec
+
+// Codec unmarshalling JSON bodies into new values of the type of the given sample,
+// e.g. JSONCodec(Order{}) decodes into *Order. With a nil sample there is no type
+// to decode into, and every body fails to decode.
+func JSONCodec(sample interface{}) Codec {
+
+	t := reflect.TypeOf(sample)
+	return CodecFunc(func(body []byte) (interface{}, error) {
+		if t == nil {
+			return nil, errors.New("JSONCodec created with a nil sample")
+		}
+
+		v := reflect.New(t).Interface()
+		if err := json.Unmarshal(body, v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	})
+}
+
+// Returns the body decoded by the receiving client's Codecs, or the raw Body
+// if no codec is registered for the message's Content-Type.
+func (m *Message) DecodedBody() interface{} {
+
+	if m.decoded != nil {
+		return m.decoded
+	}
+	return m.Body
+}
+
+// Decodes the body of a received message with the codec registered for its Content-Type.
+func (q *QueueClient) decodeValue(msg *Message) error {
+
+	if q.Codecs == nil || msg.ContentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(msg.ContentType)
+	if err != nil {
+		return nil
+	}
+
+	codec, ok := q.Codecs[mediaType]
+	if !ok {
+		return nil
+	}
+
+	value, err := codec.Decode(msg.Body)
+	if err != nil {
+		return q.withholdBody(err)
+	}
+
+	msg.decoded = value
+	return nil
+}
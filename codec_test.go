@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"net/http"
+	"testing"
+)
+
+func Test_Codecs(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	type order struct {
+		Id int `json:"id"`
+	}
+
+	cli := QueueClient{Namespace: "test", KeyName: "key", KeyValue: "keyvalue", QueueName: "test"}
+	cli.Codecs = CodecRegistry{"application/json": JSONCodec(order{})}
+
+	contentType, body := "application/json; charset=utf-8", `{"id":42}`
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		resp, _ := respondWith(201, body)(req)
+		resp.Header.Set(headerContentType, contentType)
+		return resp, nil
+	}))
+
+	msg, err := cli.GetMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o, ok := msg.DecodedBody().(*order); !ok || o.Id != 42 {
+		t.Fatalf("Expected decoded order but got %#v", msg.DecodedBody())
+	}
+
+	contentType = "text/plain"
+	msg, err = cli.GetMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b, ok := msg.DecodedBody().([]byte); !ok || string(b) != body {
+		t.Fatalf("Expected raw body for unknown content type but got %#v", msg.DecodedBody())
+	}
+
+	contentType, body = "application/json", "{"
+	if _, err := cli.GetMessage(); err == nil {
+		t.Fatalf("Expected TransformError for malformed body")
+	} else if _, ok := err.(TransformError); !ok {
+		t.Fatalf("Expected TransformError but got %v", err)
+	}
+}
+
+func Test_JSONCodec_nilSample(t *testing.T) {
+
+	if _, err := JSONCodec(nil).Decode([]byte(`{"id":42}`)); err == nil {
+		t.Fatalf("Expected error for a nil sample")
+	}
+}
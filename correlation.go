@@ -0,0 +1,35 @@
+package queue
+
+import "context"
+
+type correlationIdKey struct{}
+
+// Returns a context carrying the CorrelationId of an inbound message, typically
+// passed to the code handling it. Messages sent with SendMessageContext using
+// this context continue the correlation chain, see QueueClient.PropagateCorrelationId.
+func ContextWithMessage(ctx context.Context, msg *Message) context.Context {
+	if msg.CorrelationId == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationIdKey{}, msg.CorrelationId)
+}
+
+// Returns the CorrelationId attached to the context by ContextWithMessage, if any.
+func CorrelationIdFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIdKey{}).(string)
+	return id
+}
+
+// Sends message to a Service Bus queue. The request is cancelled when ctx is done.
+// If PropagateCorrelationId is set and the message has no CorrelationId,
+// it is sent with the one attached to ctx; msg itself is not modified.
+func (q *QueueClient) SendMessageContext(ctx context.Context, msg *Message) error {
+
+	if id := CorrelationIdFromContext(ctx); q.PropagateCorrelationId && msg.CorrelationId == "" && id != "" {
+		msg = msg.Clone()
+		msg.CorrelationId = id
+	}
+
+	_, err := q.sendMessage(ctx, msg)
+	return err
+}
@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_SendMessageContext_propagateCorrelationId(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	var properties string
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		properties = req.Header.Get(headerBrokerProperties)
+		return respondWith(201, "")(req)
+	}))
+
+	inbound := NewMessage([]byte("request"))
+	inbound.CorrelationId = "order-42"
+	ctx := ContextWithMessage(context.Background(), inbound)
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test", PropagateCorrelationId: true}
+
+	msg := NewMessage([]byte("hello"))
+	if err := cli.SendMessageContext(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(properties, `"CorrelationId":"order-42"`) {
+		t.Fatalf("Expected CorrelationId to be propagated but got %v", properties)
+	}
+
+	if msg.CorrelationId != "" {
+		t.Fatalf("Expected message not to be modified but got %v", msg.CorrelationId)
+	}
+
+	msg.CorrelationId = "explicit"
+	cli.SendMessageContext(ctx, msg)
+
+	if !strings.Contains(properties, `"CorrelationId":"explicit"`) {
+		t.Fatalf("Expected explicit CorrelationId to be kept but got %v", properties)
+	}
+
+	cli.PropagateCorrelationId = false
+	cli.SendMessageContext(ctx, NewMessage([]byte("hello")))
+
+	if strings.Contains(properties, "CorrelationId") {
+		t.Fatalf("Expected no CorrelationId without the option but got %v", properties)
+	}
+}
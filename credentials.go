@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Replaces the SAS policy name and key used to sign subsequent requests,
+// so keys can be rotated without recreating the client. Requests in flight
+// keep the token they were signed with. The client goes back to using the
+// primary key if it has fallen back to the secondary one.
+//
+// Use this rather than assigning KeyName and KeyValue once the client is in use.
+func (q *QueueClient) UpdateCredentials(keyName string, keyValue string) {
+
+	q.credMu.Lock()
+	defer q.credMu.Unlock()
+
+	q.KeyName = keyName
+	q.KeyValue = keyValue
+	q.useSecondary = false
+}
+
+// Returns the SAS policy name and key to sign requests with.
+func (q *QueueClient) credentials() (string, string) {
+
+	q.credMu.RLock()
+	defer q.credMu.RUnlock()
+
+	if q.useSecondary {
+		return q.SecondaryKeyName, q.SecondaryKeyValue
+	}
+	return q.KeyName, q.KeyValue
+}
+
+// Switches signing to the secondary key. Returns false if none is configured.
+func (q *QueueClient) fallbackToSecondary() bool {
+
+	q.credMu.Lock()
+	defer q.credMu.Unlock()
+
+	if q.SecondaryKeyValue == "" {
+		return false
+	}
+
+	if !q.useSecondary {
+		logger.DebugFields("Request rejected with primary key, switching to secondary key "+q.SecondaryKeyName, q.logFields("authorize", nil))
+		q.useSecondary = true
+	}
+	return true
+}
+
+// Sends the request made by the operation and counts it. If it is rejected as unauthorized
+// and a secondary key is configured, the client switches to the secondary key and
+// the request is retried once signed with it.
+func (q *QueueClient) do(operation string, client HttpClient, req *http.Request) (*http.Response, error) {
+
+	resp, err := client.Do(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && q.fallbackToSecondary() {
+		if retry, resignErr := q.resign(req); resignErr == nil && retry != nil {
+			resp.Body.Close()
+			resp, err = client.Do(retry)
+		}
+	}
+
+	if err != nil {
+		q.countRequest(operation, 0)
+	} else {
+		q.countRequest(operation, resp.StatusCode)
+		q.recordHealth(operation, resp.StatusCode)
+	}
+
+	return resp, err
+}
+
+// Returns a copy of the request with its SAS token signed with the current credentials,
+// or nil if the request was already signed with them.
+func (q *QueueClient) resign(req *http.Request) (*http.Request, error) {
+
+	header := req.Header.Get("Authorization")
+
+	// the signed string is made of the resource and expiry of the original token
+	var resource, expiry string
+	for _, param := range strings.Split(strings.TrimPrefix(header, "SharedAccessSignature "), "&") {
+		if strings.HasPrefix(param, "sr=") {
+			resource = strings.TrimPrefix(param, "sr=")
+		} else if strings.HasPrefix(param, "se=") {
+			expiry = strings.TrimPrefix(param, "se=")
+		}
+	}
+
+	keyName, keyValue := q.credentials()
+	sig := signatureString(keyValue, resource+"\n"+expiry)
+	resigned := fmt.Sprintf("SharedAccessSignature sig=%s&se=%s&skn=%s&sr=%s", sig, expiry, keyName, resource)
+
+	if resigned == header {
+		return nil, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+
+	retry.Header.Set("Authorization", resigned)
+	return retry, nil
+}
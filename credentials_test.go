@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_UpdateCredentials(t *testing.T) {
+
+	cli := &QueueClient{KeyName: "key", KeyValue: "keyvalue"}
+	url := "https://test.servicebus.windows.net:443/test/"
+	from := time.Date(2018, 1, 1, 1, 1, 1, 0, loc)
+
+	before := cli.makeAuthHeader(url, from)
+	cli.UpdateCredentials("rotated", "rotatedvalue")
+	after := cli.makeAuthHeader(url, from)
+
+	if !strings.Contains(after, "skn=rotated&") {
+		t.Fatalf("Expected header signed with the new key name but got %s", after)
+	}
+
+	if before == after {
+		t.Fatalf("Expected signature to change after rotation")
+	}
+}
+
+func Test_UpdateCredentials_concurrent(t *testing.T) {
+
+	cli := &QueueClient{KeyName: "key", KeyValue: "keyvalue"}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			cli.UpdateCredentials("key", "keyvalue")
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			cli.makeAuthHeader("https://test.servicebus.windows.net:443/test/", time.Now())
+		}
+	}()
+
+	wg.Wait()
+}
+
+func Test_secondaryKeyFallback(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	var bodies []string
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+
+		if strings.Contains(req.Header.Get("Authorization"), "skn=secondary&") {
+			return respondWith(201, "")(req)
+		}
+		return respondWith(401, "")(req)
+	}))
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test", KeyName: "primary", KeyValue: "primaryvalue"}
+
+	if err := cli.SendMessage(NewMessage([]byte("hello"))); err == nil {
+		t.Fatalf("Expected error without secondary key but got nil")
+	}
+
+	cli.SecondaryKeyName = "secondary"
+	cli.SecondaryKeyValue = "secondaryvalue"
+	bodies = nil
+
+	if err := cli.SendMessage(NewMessage([]byte("hello"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bodies) != 2 || bodies[1] != "hello" {
+		t.Fatalf("Expected request to be retried with its body but got %v", bodies)
+	}
+
+	// subsequent requests are signed with the secondary key right away
+	bodies = nil
+	cli.SendMessage(NewMessage([]byte("hello")))
+
+	if len(bodies) != 1 {
+		t.Fatalf("Expected 1 request but got %v", len(bodies))
+	}
+
+	// a new primary key is used again
+	cli.UpdateCredentials("primary", "rotatedvalue")
+	if name, _ := cli.credentials(); name != "primary" {
+		t.Fatalf("Expected primary key to be used but got %v", name)
+	}
+}
@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_CronSchedule_Next(t *testing.T) {
+
+	// a Friday
+	now := time.Date(2021, 1, 1, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2021, 1, 1, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 1, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17 * * *", time.Date(2021, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"30 8 * * 1-5", time.Date(2021, 1, 4, 8, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2021, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 * 1", time.Date(2021, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"5,10 0 1 */3 *", time.Date(2021, 4, 1, 0, 5, 0, 0, time.UTC)},
+		{"@daily", time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		s, err := ParseCron(test.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if next := s.Next(now); !next.Equal(test.expected) {
+			t.Fatalf("Expected next occurrence of %q at %v but got %v", test.expr, test.expected, next)
+		}
+	}
+
+	s, _ := ParseCron("0 0 30 2 *")
+	if next := s.Next(now); !next.IsZero() {
+		t.Fatalf("Expected no occurrence but got %v", next)
+	}
+}
+
+func Test_ParseCron_invalid(t *testing.T) {
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Fatalf("Expected %q to be rejected", expr)
+		}
+	}
+}
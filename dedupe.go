@@ -0,0 +1,128 @@
+package queue
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DedupeStore remembers ids of processed messages, giving effectively-once
+// processing for handlers which are not idempotent.
+// Implementations must be safe for concurrent use.
+type DedupeStore interface {
+	// Reports whether the message id was marked as processed.
+	Seen(id string) (bool, error)
+
+	// Marks the message id as processed.
+	Mark(id string) error
+}
+
+// In-memory DedupeStore remembering up to a fixed number of most recently
+// processed ids for a limited time.
+type MemoryDedupeStore struct {
+	capacity int
+	window   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type dedupeEntry struct {
+	id     string
+	marked time.Time
+}
+
+// Defaults of dedupe stores created without a capacity or window. The window matches
+// the default duplicate detection window of Service Bus.
+const (
+	DefaultDedupeCapacity = 10000
+	DefaultDedupeWindow   = 10 * time.Minute
+)
+
+// Creates a store remembering at most capacity ids, each for the duration of window.
+// A capacity or window of zero uses DefaultDedupeCapacity or DefaultDedupeWindow.
+func NewMemoryDedupeStore(capacity int, window time.Duration) *MemoryDedupeStore {
+
+	if capacity <= 0 {
+		capacity = DefaultDedupeCapacity
+	}
+	if window <= 0 {
+		window = DefaultDedupeWindow
+	}
+
+	return &MemoryDedupeStore{
+		capacity: capacity,
+		window:   window,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+func (s *MemoryDedupeStore) Seen(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[id]
+	if !ok {
+		return false, nil
+	}
+
+	if time.Since(e.Value.(*dedupeEntry).marked) > s.window {
+		s.order.Remove(e)
+		delete(s.entries, id)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (s *MemoryDedupeStore) Mark(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[id]; ok {
+		e.Value.(*dedupeEntry).marked = time.Now()
+		s.order.MoveToFront(e)
+		return nil
+	}
+
+	s.entries[id] = s.order.PushFront(&dedupeEntry{id, time.Now()})
+
+	for s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*dedupeEntry).id)
+	}
+
+	return nil
+}
+
+// Reports whether the message was already processed according to the client's dedupe store.
+// Store failures are logged and the message is treated as new.
+func (q *QueueClient) isDuplicate(msg *Message) bool {
+
+	if q.Dedupe == nil || msg.Id == "" {
+		return false
+	}
+
+	seen, err := q.Dedupe.Seen(msg.Id)
+	if err != nil {
+		q.logError("Dedupe store lookup failed", "receive", msg, err)
+		return false
+	}
+
+	return seen
+}
+
+// Records a completed message in the client's dedupe store.
+func (q *QueueClient) markProcessed(msg *Message) {
+
+	if q.Dedupe == nil || msg.Id == "" {
+		return
+	}
+
+	if err := q.Dedupe.Mark(msg.Id); err != nil {
+		q.logError("Dedupe store update failed", "complete", msg, err)
+	}
+}
@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_MemoryDedupeStore(t *testing.T) {
+
+	s := NewMemoryDedupeStore(2, time.Hour)
+
+	s.Mark("a")
+	s.Mark("b")
+	s.Mark("a")
+	s.Mark("c")
+
+	for id, expected := range map[string]bool{"a": true, "b": false, "c": true, "d": false} {
+		if seen, _ := s.Seen(id); seen != expected {
+			t.Fatalf("Expected seen %v for %s but got %v", expected, id, seen)
+		}
+	}
+}
+
+func Test_MemoryDedupeStore_window(t *testing.T) {
+
+	s := NewMemoryDedupeStore(10, time.Millisecond)
+	s.Mark("a")
+
+	time.Sleep(5 * time.Millisecond)
+
+	if seen, _ := s.Seen("a"); seen {
+		t.Fatal("Expected id outside the window not to be seen")
+	}
+}
+
+func Test_MemoryDedupeStore_defaults(t *testing.T) {
+
+	s := NewMemoryDedupeStore(0, 0)
+	s.Mark("a")
+
+	if seen, _ := s.Seen("a"); !seen {
+		t.Fatal("Expected id to be remembered with the default capacity and window")
+	}
+
+	if s.capacity != DefaultDedupeCapacity || s.window != DefaultDedupeWindow {
+		t.Fatalf("Expected defaults but got capacity %v and window %v", s.capacity, s.window)
+	}
+}
+
+func Test_Dedupe(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	ids := []string{"1", "1", "2"}
+	var deleted []string
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "DELETE" {
+			deleted = append(deleted, req.URL.Path)
+			return respondWith(200, "")(req)
+		}
+
+		id := ids[0]
+		ids = ids[1:]
+
+		resp, _ := respondWith(200, "hello")(req)
+		resp.Header.Set(headerBrokerProperties, `{"MessageId":"`+id+`","LockToken":"lock"}`)
+		return resp, nil
+	}))
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test", Dedupe: NewMemoryDedupeStore(10, time.Hour)}
+
+	msg, err := cli.GetMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cli.DeleteMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err = cli.GetMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Id != "2" {
+		t.Fatalf("Expected duplicate to be skipped but got message %s", msg.Id)
+	}
+
+	expected := []string{"/test/messages/1/lock", "/test/messages/1/lock"}
+	if len(deleted) != 2 || deleted[0] != expected[0] || deleted[1] != expected[1] {
+		t.Fatalf("Expected duplicate to be completed but got deletes %v", deleted)
+	}
+}
@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// Interval of QueueDepthWatcher without an Interval.
+const DefaultDepthWatchInterval = 30 * time.Second
+
+// Periodically fetches the message counts of a queue, e.g. for autoscaling logic
+// or dashboards.
+type QueueDepthWatcher struct {
+	Queue *QueueClient
+
+	// How often the counts are fetched, DefaultDepthWatchInterval if zero.
+	Interval time.Duration
+
+	// Called with the counts after every successful fetch.
+	OnDepth func(info *QueueRuntimeInfo)
+
+	// Optional callback for failed fetches. Failures are logged if not set.
+	OnError func(err error)
+}
+
+// Fetches the counts right away and then every Interval. Blocks until ctx is done.
+func (w *QueueDepthWatcher) Watch(ctx context.Context) {
+	w.watch(ctx, w.OnDepth)
+}
+
+// Watches the queue in the background and delivers the counts on the returned channel,
+// which is closed once ctx is done. Only the most recent counts are kept for a slow
+// receiver. OnDepth is called as well if set.
+func (w *QueueDepthWatcher) Depths(ctx context.Context) <-chan *QueueRuntimeInfo {
+
+	depths := make(chan *QueueRuntimeInfo, 1)
+
+	go func() {
+		defer close(depths)
+
+		w.watch(ctx, func(info *QueueRuntimeInfo) {
+			if w.OnDepth != nil {
+				w.OnDepth(info)
+			}
+
+			// replace counts not received yet
+			select {
+			case <-depths:
+			default:
+			}
+			depths <- info
+		})
+	}()
+
+	return depths
+}
+
+func (w *QueueDepthWatcher) watch(ctx context.Context, onDepth func(info *QueueRuntimeInfo)) {
+
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultDepthWatchInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		w.poll(ctx, onDepth)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *QueueDepthWatcher) poll(ctx context.Context, onDepth func(info *QueueRuntimeInfo)) {
+
+	info, err := w.Queue.GetQueueRuntimeInfo(ctx)
+	if err == nil {
+		onDepth(info)
+		return
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	if w.OnError != nil {
+		w.OnError(err)
+	} else {
+		w.Queue.logError("Fetching queue depth failed", "get_queue", nil, err)
+	}
+}
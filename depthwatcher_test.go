@@ -0,0 +1,108 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_QueueDepthWatcher(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(respondWith(200, queueEntryXml))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	depths := make(chan int64, 10)
+	w := &QueueDepthWatcher{
+		Queue:    &q,
+		Interval: time.Millisecond,
+		OnDepth: func(info *QueueRuntimeInfo) {
+			select {
+			case depths <- info.ActiveMessageCount:
+			default:
+			}
+		},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.Watch(ctx)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		if depth := <-depths; depth != 5 {
+			t.Fatalf("Expected depth 5 but got %v", depth)
+		}
+	}
+
+	cancel()
+	<-done
+}
+
+func Test_QueueDepthWatcher_onError(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(respondWith(401, ""))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var failure error
+	w := &QueueDepthWatcher{
+		Queue:    &q,
+		Interval: time.Hour,
+		OnDepth:  func(info *QueueRuntimeInfo) {},
+		OnError: func(err error) {
+			failure = err
+			cancel()
+		},
+	}
+
+	w.Watch(ctx)
+
+	if _, ok := failure.(NotAuthorizedError); !ok {
+		t.Fatalf("Expected error type NotAuthorizedError but got %v", failure)
+	}
+}
+
+func Test_QueueDepthWatcher_Depths(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(respondWith(200, queueEntryXml))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	w := &QueueDepthWatcher{Queue: &q}
+	depths := w.Depths(ctx)
+
+	// the first fetch happens right away, not after the default interval
+	select {
+	case info := <-depths:
+		if info.ActiveMessageCount != 5 {
+			t.Fatalf("Expected depth 5 but got %v", info.ActiveMessageCount)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected counts to be delivered")
+	}
+
+	cancel()
+
+	closed := make(chan struct{})
+	go func() {
+		for range depths {
+		}
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected channel to be closed")
+	}
+}
@@ -0,0 +1,304 @@
+package queue
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Properties of a queue. Zero durations and counts stand for the service defaults.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/queues
+type QueueDescription struct {
+	// How long a received message stays locked, at most 5 minutes.
+	LockDuration time.Duration
+
+	// Maximal size of the queue.
+	MaxSizeInMegabytes int
+
+	// Whether duplicate messages are detected by MessageId. Can't be changed after creation.
+	RequiresDuplicateDetection bool
+
+	// Whether the queue requires sessions. Can't be changed after creation.
+	RequiresSession bool
+
+	// Time to live of messages which don't specify one.
+	DefaultMessageTimeToLive time.Duration
+
+	// Whether expired messages are moved to the dead-letter queue.
+	DeadLetteringOnMessageExpiration bool
+
+	// Number of deliveries after which a message is dead-lettered.
+	MaxDeliveryCount int
+
+	// Period in which duplicate messages are detected.
+	DuplicateDetectionHistoryTimeWindow time.Duration
+
+	// Idle time after which the queue is deleted automatically.
+	AutoDeleteOnIdle time.Duration
+
+	// Whether the queue is partitioned. Can't be changed after creation.
+	EnablePartitioning bool
+
+	// Optional name of a queue or topic messages are forwarded to.
+	ForwardTo string
+
+	// Optional name of a queue or topic dead-lettered messages are forwarded to.
+	ForwardDeadLetteredMessagesTo string
+
+	// The following properties are reported by GetQueue and ignored by EnsureQueue.
+
+	// Whether server-side batched operations are enabled.
+	EnableBatchedOperations bool
+
+	// Status of the queue e.g. Active or Disabled.
+	Status string
+
+	// Size of the queue in bytes.
+	SizeInBytes int64
+
+	// Number of messages in the queue.
+	MessageCount int64
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Wire format of a queue description. Elements must be in the order of the service's schema.
+type queueDescriptionXml struct {
+	XMLName                          xml.Name `xml:"http://schemas.microsoft.com/netservices/2010/10/servicebus/connect QueueDescription"`
+	LockDuration                     string   `xml:"LockDuration,omitempty"`
+	MaxSizeInMegabytes               int      `xml:"MaxSizeInMegabytes,omitempty"`
+	RequiresDuplicateDetection       *bool    `xml:"RequiresDuplicateDetection,omitempty"`
+	RequiresSession                  *bool    `xml:"RequiresSession,omitempty"`
+	DefaultMessageTimeToLive         string   `xml:"DefaultMessageTimeToLive,omitempty"`
+	DeadLetteringOnMessageExpiration *bool    `xml:"DeadLetteringOnMessageExpiration,omitempty"`
+	DuplicateDetectionHistoryTime    string   `xml:"DuplicateDetectionHistoryTimeWindow,omitempty"`
+	MaxDeliveryCount                 int      `xml:"MaxDeliveryCount,omitempty"`
+	EnableBatchedOperations          *bool    `xml:"EnableBatchedOperations,omitempty"`
+	SizeInBytes                      int64    `xml:"SizeInBytes,omitempty"`
+	MessageCount                     int64    `xml:"MessageCount,omitempty"`
+	Status                           string   `xml:"Status,omitempty"`
+	ForwardTo                        string   `xml:"ForwardTo,omitempty"`
+	CreatedAt                        string   `xml:"CreatedAt,omitempty"`
+	UpdatedAt                        string   `xml:"UpdatedAt,omitempty"`
+	CountDetails                     *struct {
+		ActiveMessageCount             int64 `xml:"ActiveMessageCount"`
+		DeadLetterMessageCount         int64 `xml:"DeadLetterMessageCount"`
+		ScheduledMessageCount          int64 `xml:"ScheduledMessageCount"`
+		TransferMessageCount           int64 `xml:"TransferMessageCount"`
+		TransferDeadLetterMessageCount int64 `xml:"TransferDeadLetterMessageCount"`
+	} `xml:"CountDetails,omitempty"`
+	AutoDeleteOnIdle              string `xml:"AutoDeleteOnIdle,omitempty"`
+	EnablePartitioning            *bool  `xml:"EnablePartitioning,omitempty"`
+	ForwardDeadLetteredMessagesTo string `xml:"ForwardDeadLetteredMessagesTo,omitempty"`
+}
+
+type queueEntry struct {
+	XMLName xml.Name `xml:"entry"`
+	Title   string   `xml:"title"`
+	Content struct {
+		Description queueDescriptionXml `xml:"QueueDescription"`
+	} `xml:"content"`
+}
+
+type entryBody struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom entry"`
+	Content struct {
+		Type        string `xml:"type,attr"`
+		Description interface{}
+	} `xml:"content"`
+}
+
+func (d queueDescriptionXml) toDescription() (QueueDescription, error) {
+
+	desc := QueueDescription{
+		MaxSizeInMegabytes:               d.MaxSizeInMegabytes,
+		RequiresDuplicateDetection:       isTrue(d.RequiresDuplicateDetection),
+		RequiresSession:                  isTrue(d.RequiresSession),
+		DeadLetteringOnMessageExpiration: isTrue(d.DeadLetteringOnMessageExpiration),
+		MaxDeliveryCount:                 d.MaxDeliveryCount,
+		EnablePartitioning:               isTrue(d.EnablePartitioning),
+		ForwardTo:                        d.ForwardTo,
+		ForwardDeadLetteredMessagesTo:    d.ForwardDeadLetteredMessagesTo,
+		EnableBatchedOperations:          isTrue(d.EnableBatchedOperations),
+		Status:                           d.Status,
+		SizeInBytes:                      d.SizeInBytes,
+		MessageCount:                     d.MessageCount,
+	}
+
+	durations := []struct {
+		value  string
+		target *time.Duration
+	}{
+		{d.LockDuration, &desc.LockDuration},
+		{d.DefaultMessageTimeToLive, &desc.DefaultMessageTimeToLive},
+		{d.DuplicateDetectionHistoryTime, &desc.DuplicateDetectionHistoryTimeWindow},
+		{d.AutoDeleteOnIdle, &desc.AutoDeleteOnIdle},
+	}
+
+	for _, duration := range durations {
+		v, err := parseDuration(duration.value)
+		if err != nil {
+			return desc, err
+		}
+		*duration.target = v
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, d.CreatedAt); err == nil {
+		desc.CreatedAt = t
+	}
+	if t, err := time.Parse(time.RFC3339Nano, d.UpdatedAt); err == nil {
+		desc.UpdatedAt = t
+	}
+
+	return desc, nil
+}
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
+
+// Sets the properties given in desc on the wire format, keeping the others.
+func (d *queueDescriptionXml) apply(desc QueueDescription) {
+
+	if desc.LockDuration > 0 {
+		d.LockDuration = formatDuration(desc.LockDuration)
+	}
+	if desc.MaxSizeInMegabytes > 0 {
+		d.MaxSizeInMegabytes = desc.MaxSizeInMegabytes
+	}
+	if desc.DefaultMessageTimeToLive > 0 {
+		d.DefaultMessageTimeToLive = formatDuration(desc.DefaultMessageTimeToLive)
+	}
+	if desc.MaxDeliveryCount > 0 {
+		d.MaxDeliveryCount = desc.MaxDeliveryCount
+	}
+	if desc.DuplicateDetectionHistoryTimeWindow > 0 {
+		d.DuplicateDetectionHistoryTime = formatDuration(desc.DuplicateDetectionHistoryTimeWindow)
+	}
+	if desc.AutoDeleteOnIdle > 0 {
+		d.AutoDeleteOnIdle = formatDuration(desc.AutoDeleteOnIdle)
+	}
+	if desc.ForwardTo != "" {
+		d.ForwardTo = desc.ForwardTo
+	}
+	if desc.ForwardDeadLetteredMessagesTo != "" {
+		d.ForwardDeadLetteredMessagesTo = desc.ForwardDeadLetteredMessagesTo
+	}
+
+	d.RequiresDuplicateDetection = &desc.RequiresDuplicateDetection
+	d.RequiresSession = &desc.RequiresSession
+	d.DeadLetteringOnMessageExpiration = &desc.DeadLetteringOnMessageExpiration
+	d.EnablePartitioning = &desc.EnablePartitioning
+}
+
+// Clears the read-only properties, which must not be sent to the service.
+func (d *queueDescriptionXml) clearReadOnly() {
+	d.SizeInBytes = 0
+	d.MessageCount = 0
+	d.CreatedAt = ""
+	d.UpdatedAt = ""
+	d.CountDetails = nil
+}
+
+// Wraps an entity description in an Atom entry as expected by create and update requests.
+func marshalEntry(description interface{}) ([]byte, error) {
+
+	entry := entryBody{}
+	entry.Content.Type = "application/xml"
+	entry.Content.Description = description
+
+	body, err := xml.Marshal(entry)
+	if err != nil {
+		return nil, wrap(err, "Error encoding entity description")
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// Returns the names of the properties given in desc which differ from current,
+// separately for mutable and immutable ones.
+func (desc QueueDescription) drift(current QueueDescription) (mutable []string, immutable []string) {
+
+	if desc.LockDuration > 0 && desc.LockDuration != current.LockDuration {
+		mutable = append(mutable, "LockDuration")
+	}
+	if desc.MaxSizeInMegabytes > 0 && desc.MaxSizeInMegabytes != current.MaxSizeInMegabytes {
+		mutable = append(mutable, "MaxSizeInMegabytes")
+	}
+	if desc.DefaultMessageTimeToLive > 0 && desc.DefaultMessageTimeToLive != current.DefaultMessageTimeToLive {
+		mutable = append(mutable, "DefaultMessageTimeToLive")
+	}
+	if desc.DeadLetteringOnMessageExpiration != current.DeadLetteringOnMessageExpiration {
+		mutable = append(mutable, "DeadLetteringOnMessageExpiration")
+	}
+	if desc.MaxDeliveryCount > 0 && desc.MaxDeliveryCount != current.MaxDeliveryCount {
+		mutable = append(mutable, "MaxDeliveryCount")
+	}
+	if desc.DuplicateDetectionHistoryTimeWindow > 0 && desc.DuplicateDetectionHistoryTimeWindow != current.DuplicateDetectionHistoryTimeWindow {
+		mutable = append(mutable, "DuplicateDetectionHistoryTimeWindow")
+	}
+	if desc.AutoDeleteOnIdle > 0 && desc.AutoDeleteOnIdle != current.AutoDeleteOnIdle {
+		mutable = append(mutable, "AutoDeleteOnIdle")
+	}
+	if desc.ForwardTo != "" && desc.ForwardTo != current.ForwardTo {
+		mutable = append(mutable, "ForwardTo")
+	}
+	if desc.ForwardDeadLetteredMessagesTo != "" && desc.ForwardDeadLetteredMessagesTo != current.ForwardDeadLetteredMessagesTo {
+		mutable = append(mutable, "ForwardDeadLetteredMessagesTo")
+	}
+	if desc.RequiresDuplicateDetection != current.RequiresDuplicateDetection {
+		immutable = append(immutable, "RequiresDuplicateDetection")
+	}
+	if desc.RequiresSession != current.RequiresSession {
+		immutable = append(immutable, "RequiresSession")
+	}
+	if desc.EnablePartitioning != current.EnablePartitioning {
+		immutable = append(immutable, "EnablePartitioning")
+	}
+
+	return mutable, immutable
+}
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// Parses an ISO 8601 duration as used by Service Bus, e.g. PT1M or P14D.
+// An empty string is a zero duration. Durations too long for time.Duration are capped.
+func parseDuration(s string) (time.Duration, error) {
+
+	if s == "" {
+		return 0, nil
+	}
+
+	m := isoDuration.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("Invalid duration %q", s)
+	}
+
+	var ns float64
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+1] == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(m[i+1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid duration %q", s)
+		}
+		ns += v * float64(unit)
+	}
+
+	// the service's default of P10675199DT2H48M5.4775807S exceeds time.Duration
+	if ns >= math.MaxInt64 {
+		return math.MaxInt64, nil
+	}
+
+	return time.Duration(ns), nil
+}
+
+// Formats a duration in ISO 8601 as accepted by Service Bus, e.g. PT90S.
+func formatDuration(d time.Duration) string {
+	return "PT" + strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+}
@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseDuration(t *testing.T) {
+
+	cases := map[string]time.Duration{
+		"":                           0,
+		"PT1M":                       time.Minute,
+		"P14D":                       14 * 24 * time.Hour,
+		"PT30S":                      30 * time.Second,
+		"P1DT2H3M4.5S":               26*time.Hour + 3*time.Minute + 4500*time.Millisecond,
+		"P10675199DT2H48M5.4775807S": math.MaxInt64,
+	}
+
+	for s, expected := range cases {
+		d, err := parseDuration(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d != expected {
+			t.Fatalf("Expected %v for %q but got %v", expected, s, d)
+		}
+	}
+
+	if _, err := parseDuration("1 minute"); err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
+
+func Test_formatDuration(t *testing.T) {
+
+	for _, d := range []time.Duration{time.Minute, 1500 * time.Millisecond, 14 * 24 * time.Hour} {
+		parsed, err := parseDuration(formatDuration(d))
+		if err != nil || parsed != d {
+			t.Fatalf("Expected %v to round trip but got %v %v", d, parsed, err)
+		}
+	}
+}
+
+func Test_marshalEntry(t *testing.T) {
+
+	d := queueDescriptionXml{}
+	d.apply(QueueDescription{LockDuration: time.Minute, MaxDeliveryCount: 5})
+
+	body, err := marshalEntry(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<entry xmlns="http://www.w3.org/2005/Atom"><content type="application/xml">` +
+		`<QueueDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect">` +
+		`<LockDuration>PT60S</LockDuration><RequiresDuplicateDetection>false</RequiresDuplicateDetection>` +
+		`<RequiresSession>false</RequiresSession><DeadLetteringOnMessageExpiration>false</DeadLetteringOnMessageExpiration>` +
+		`<MaxDeliveryCount>5</MaxDeliveryCount><EnablePartitioning>false</EnablePartitioning></QueueDescription></content></entry>`
+
+	if !strings.HasSuffix(string(body), expected) {
+		t.Fatalf("Expected body %s but got %s", expected, string(body))
+	}
+}
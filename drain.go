@@ -0,0 +1,42 @@
+package queue
+
+import "context"
+
+// Receives and handles messages until the queue is empty, maxMessages messages have been
+// handled (if maxMessages is positive) or ctx is done, for batch jobs run on a schedule.
+// Messages the handler succeeds on are completed. If the handler fails, the message is
+// unlocked and draining stops with the handler's error.
+//
+// Returns the number of messages handled successfully.
+func (q *QueueClient) DrainQueue(ctx context.Context, maxMessages int, handler func(msg *Message) error) (int, error) {
+
+	handled := 0
+
+	for maxMessages <= 0 || handled < maxMessages {
+
+		if err := ctx.Err(); err != nil {
+			return handled, err
+		}
+
+		msg, err := q.receive(ctx, 0)
+		if _, empty := err.(NoMessagesAvailableError); empty {
+			return handled, nil
+		}
+		if err != nil {
+			return handled, err
+		}
+
+		if err := handler(msg); err != nil {
+			q.unlockAfterFailure(msg)
+			return handled, err
+		}
+
+		if err := q.DeleteMessage(msg); err != nil {
+			return handled, err
+		}
+
+		handled++
+	}
+
+	return handled, nil
+}
@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// Fakes a queue holding the given number of messages. Unlocked messages are not redelivered.
+func fakeQueue(available int, deleted *int) fakeHttpClient {
+	received := 0
+	return func(req *http.Request) (*http.Response, error) {
+		switch req.Method {
+		case "POST":
+			if received == available {
+				return respondWith(204, "")(req)
+			}
+			received++
+			resp, _ := respondWith(201, "hello")(req)
+			resp.Header.Set(headerBrokerProperties, `{"MessageId":"`+strconv.Itoa(received)+`","LockToken":"lock"}`)
+			return resp, nil
+		case "DELETE":
+			*deleted++
+		}
+		return respondWith(200, "")(req)
+	}
+}
+
+func Test_DrainQueue(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	deleted := 0
+	SetHttpClient(fakeQueue(3, &deleted))
+
+	handled, err := q.DrainQueue(context.Background(), 0, func(msg *Message) error {
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if handled != 3 || deleted != 3 {
+		t.Fatalf("Expected 3 messages handled and completed but got %v and %v", handled, deleted)
+	}
+}
+
+func Test_DrainQueue_limit(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	deleted := 0
+	SetHttpClient(fakeQueue(3, &deleted))
+
+	handled, err := q.DrainQueue(context.Background(), 2, func(msg *Message) error {
+		return nil
+	})
+
+	if err != nil || handled != 2 {
+		t.Fatalf("Expected 2 messages handled but got %v %v", handled, err)
+	}
+}
+
+func Test_DrainQueue_handlerError(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	deleted := 0
+	SetHttpClient(fakeQueue(3, &deleted))
+
+	failure := errors.New("failed")
+	handled, err := q.DrainQueue(context.Background(), 0, func(msg *Message) error {
+		if msg.Id == "2" {
+			return failure
+		}
+		return nil
+	})
+
+	if err != failure || handled != 1 || deleted != 1 {
+		t.Fatalf("Expected draining to stop after 1 message but got %v %v %v", handled, deleted, err)
+	}
+}
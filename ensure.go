@@ -0,0 +1,247 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// Action taken by an Ensure operation.
+type EnsureAction int
+
+const (
+	// The entity existed with the requested properties.
+	EnsureUnchanged EnsureAction = iota
+
+	// The entity didn't exist and was created.
+	EnsureCreated
+
+	// The entity existed and its drifted properties were updated.
+	EnsureUpdated
+)
+
+func (a EnsureAction) String() string {
+	switch a {
+	case EnsureCreated:
+		return "created"
+	case EnsureUpdated:
+		return "updated"
+	}
+	return "unchanged"
+}
+
+// Creates the queue with the given properties if it doesn't exist, or updates the
+// properties which differ from desc if it does. Returns an error without changing
+// anything if properties which can't be changed after creation differ.
+// Zero durations and counts in desc are left to the service defaults and not checked.
+// Properties QueueDescription doesn't cover, such as AuthorizationRules, are kept on update.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/create-queue
+func (q *QueueClient) EnsureQueue(ctx context.Context, desc QueueDescription) (EnsureAction, error) {
+
+	body, err := q.manage(ctx, "get_queue", "GET", q.QueueName, nil, nil)
+	if err != nil {
+		return EnsureUnchanged, err
+	}
+
+	entry := queueEntry{}
+	err = parseEntry(body, &entry)
+
+	if _, missing := err.(QueueDontExistError); missing {
+		d := queueDescriptionXml{}
+		d.apply(desc)
+		if err := q.putEntity(ctx, "create_queue", q.QueueName, d, nil); err != nil {
+			return EnsureUnchanged, err
+		}
+		return EnsureCreated, nil
+	}
+
+	if err != nil {
+		return EnsureUnchanged, err
+	}
+
+	current, err := entry.Content.Description.toDescription()
+	if err != nil {
+		return EnsureUnchanged, err
+	}
+
+	mutable, immutable := desc.drift(current)
+	if len(immutable) > 0 {
+		return EnsureUnchanged, fmt.Errorf("Queue %s can't be updated, %s can't be changed after creation", q.QueueName, strings.Join(immutable, ", "))
+	}
+
+	if len(mutable) == 0 {
+		return EnsureUnchanged, nil
+	}
+
+	logger.DebugFields("Updating drifted queue properties "+strings.Join(mutable, ", "), q.logFields("update_queue", nil))
+
+	d := entry.Content.Description
+	d.clearReadOnly()
+	d.apply(desc)
+	if err := q.updateEntity(ctx, "update_queue", q.QueueName, body, d); err != nil {
+		return EnsureUnchanged, err
+	}
+	return EnsureUpdated, nil
+}
+
+// Updates the entity at path, whose current entry is raw, with the properties of updated.
+// Only the elements modelled by updated are replaced, see patchDescription.
+func (q *QueueClient) updateEntity(ctx context.Context, operation string, path string, raw []byte, updated interface{}) error {
+
+	description, err := patchDescription(raw, updated)
+	if err != nil {
+		return err
+	}
+
+	body := []byte(xml.Header + `<entry xmlns="http://www.w3.org/2005/Atom"><content type="application/xml">`)
+	body = append(body, description...)
+	body = append(body, "</content></entry>"...)
+
+	_, err = q.manage(ctx, operation, "PUT", path, http.Header{"If-Match": {"*"}}, body)
+	return err
+}
+
+// Returns the entity description of the raw entry with the elements modelled by updated,
+// one of the description types of this package, replaced by their values in updated.
+// Elements the package doesn't model, e.g. AuthorizationRules or UserMetadata, are kept
+// verbatim and in place, as an update resets any property it omits to the default.
+// Modelled elements missing from updated, e.g. cleared read-only ones, are removed.
+func patchDescription(raw []byte, updated interface{}) ([]byte, error) {
+
+	encoded, err := xml.Marshal(updated)
+	if err != nil {
+		return nil, wrap(err, "Error encoding entity description")
+	}
+
+	desired, err := splitElement(encoded, "")
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := splitElement(raw, desired.name)
+	if err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf(updated)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	order := xmlElementOrder(t)
+
+	present := map[string]bool{}
+	for _, c := range current.children {
+		present[c.name] = true
+	}
+
+	replaced := map[string][]byte{}
+	for _, c := range desired.children {
+		replaced[c.name] = c.data
+	}
+
+	out := &bytes.Buffer{}
+	out.Write(current.start)
+	next := 0
+
+	// writes the desired elements missing from raw which precede position
+	insertBefore := func(position int) {
+		for ; next < len(desired.children) && order[desired.children[next].name] < position; next++ {
+			if c := desired.children[next]; !present[c.name] {
+				out.Write(c.data)
+			}
+		}
+	}
+
+	for _, c := range current.children {
+		position, modelled := order[c.name]
+		if !modelled {
+			out.Write(c.data)
+			continue
+		}
+
+		insertBefore(position)
+		if data, ok := replaced[c.name]; ok {
+			out.Write(data)
+		}
+	}
+
+	insertBefore(len(order))
+	out.Write(current.end)
+
+	return out.Bytes(), nil
+}
+
+// An XML element split into its verbatim start and end tags and child elements.
+type xmlElement struct {
+	name     string
+	start    []byte
+	end      []byte
+	children []xmlChild
+}
+
+type xmlChild struct {
+	name string
+	data []byte
+}
+
+// Splits the first element with the given local name, or the first element if name is empty.
+func splitElement(data []byte, name string) (xmlElement, error) {
+
+	e := xmlElement{}
+	d := xml.NewDecoder(bytes.NewReader(data))
+
+	for e.start == nil {
+		offset := d.InputOffset()
+		t, err := d.Token()
+		if err == io.EOF {
+			return e, fmt.Errorf("Entity description %s not found", name)
+		}
+		if err != nil {
+			return e, wrap(err, "Error parsing entity description")
+		}
+		if s, ok := t.(xml.StartElement); ok && (name == "" || s.Name.Local == name) {
+			e.name = s.Name.Local
+			e.start = data[offset:d.InputOffset()]
+		}
+	}
+
+	for {
+		offset := d.InputOffset()
+		t, err := d.Token()
+		if err != nil {
+			return e, wrap(err, "Error parsing entity description")
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			if err := d.Skip(); err != nil {
+				return e, wrap(err, "Error parsing entity description")
+			}
+			e.children = append(e.children, xmlChild{t.Name.Local, data[offset:d.InputOffset()]})
+		case xml.EndElement:
+			e.end = data[offset:d.InputOffset()]
+			return e, nil
+		}
+	}
+}
+
+// Returns the position of each element modelled by the description type t.
+func xmlElementOrder(t reflect.Type) map[string]int {
+
+	order := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("xml"), ",")[0]
+		if f.Name == "XMLName" || name == "" {
+			continue
+		}
+		order[name] = i
+	}
+	return order
+}
@@ -0,0 +1,143 @@
+package queue
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Fakes the management endpoint of a single queue described by entry, empty if missing.
+func fakeManagement(entry *string, puts *[]*http.Request) fakeHttpClient {
+	return func(req *http.Request) (*http.Response, error) {
+		switch req.Method {
+		case "GET":
+			if *entry == "" {
+				return respondWith(200, emptyFeedXml)(req)
+			}
+			return respondWith(200, *entry)(req)
+		case "PUT":
+			body, _ := ioutil.ReadAll(req.Body)
+			*entry = string(body)
+			*puts = append(*puts, req)
+			return respondWith(201, *entry)(req)
+		}
+		return respondWith(400, "")(req)
+	}
+}
+
+func Test_EnsureQueue(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	entry := ""
+	var puts []*http.Request
+	SetHttpClient(fakeManagement(&entry, &puts))
+
+	desc := QueueDescription{LockDuration: time.Minute, MaxDeliveryCount: 5}
+
+	tests := []struct {
+		desc     QueueDescription
+		expected EnsureAction
+	}{
+		{desc, EnsureCreated},
+		{desc, EnsureUnchanged},
+		{QueueDescription{LockDuration: 2 * time.Minute}, EnsureUpdated},
+	}
+
+	for _, test := range tests {
+		action, err := q.EnsureQueue(context.Background(), test.desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action != test.expected {
+			t.Fatalf("Expected action %v but got %v", test.expected, action)
+		}
+	}
+
+	if len(puts) != 2 {
+		t.Fatalf("Expected 2 PUT requests but got %v", len(puts))
+	}
+
+	if puts[0].Header.Get("If-Match") != "" || puts[1].Header.Get("If-Match") != "*" {
+		t.Fatalf("Expected only the update to be conditional")
+	}
+
+	// the update keeps properties it doesn't change
+	if !strings.Contains(entry, "<LockDuration>PT120S</LockDuration>") || !strings.Contains(entry, "<MaxDeliveryCount>5</MaxDeliveryCount>") {
+		t.Fatalf("Unexpected updated description %s", entry)
+	}
+}
+
+func Test_EnsureQueue_immutable(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	entry := queueEntryXml
+	var puts []*http.Request
+	SetHttpClient(fakeManagement(&entry, &puts))
+
+	_, err := q.EnsureQueue(context.Background(), QueueDescription{RequiresSession: true})
+
+	if err == nil || !strings.Contains(err.Error(), "RequiresSession") {
+		t.Fatalf("Expected error naming RequiresSession but got %v", err)
+	}
+
+	if len(puts) != 0 {
+		t.Fatalf("Expected no update but got %v", len(puts))
+	}
+}
+
+func Test_EnsureQueue_keepsUnmodelledProperties(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	entry := strings.Replace(queueEntryXml, "<Status>Active</Status>", `<Status>Active</Status>
+      <AuthorizationRules>
+        <AuthorizationRule i:type="SharedAccessAuthorizationRule">
+          <KeyName>listen</KeyName>
+        </AuthorizationRule>
+      </AuthorizationRules>
+      <EnableExpress>true</EnableExpress>
+      <UserMetadata>owner=billing</UserMetadata>`, 1)
+	var puts []*http.Request
+	SetHttpClient(fakeManagement(&entry, &puts))
+
+	desc := QueueDescription{LockDuration: 2 * time.Minute, ForwardTo: "other"}
+
+	action, err := q.EnsureQueue(context.Background(), desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action != EnsureUpdated {
+		t.Fatalf("Expected action %v but got %v", EnsureUpdated, action)
+	}
+
+	expected := []string{
+		"<LockDuration>PT120S</LockDuration>",
+		"<MaxDeliveryCount>10</MaxDeliveryCount>",
+		`<AuthorizationRule i:type="SharedAccessAuthorizationRule">`,
+		"<EnableExpress>true</EnableExpress>",
+		"<UserMetadata>owner=billing</UserMetadata>",
+		"<ForwardTo>other</ForwardTo>",
+		"<AutoDeleteOnIdle>P10675199DT2H48M5.4775807S</AutoDeleteOnIdle>",
+	}
+	last := -1
+	for _, e := range expected {
+		i := strings.Index(entry, e)
+		if i <= last {
+			t.Fatalf("Expected %s in place in the updated description %s", e, entry)
+		}
+		last = i
+	}
+
+	if strings.Contains(entry, "<CreatedAt>") || strings.Contains(entry, "<CountDetails") {
+		t.Fatalf("Expected read-only properties to be removed from %s", entry)
+	}
+
+	if action, err := q.EnsureQueue(context.Background(), desc); err != nil || action != EnsureUnchanged {
+		t.Fatalf("Expected the updated description to be unchanged but got %v %v", action, err)
+	}
+}
@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Environment variables read by NewClientFromEnvironment.
+const (
+	EnvConnectionString = "SERVICEBUS_CONNECTION_STRING"
+	EnvNamespace        = "SERVICEBUS_NAMESPACE"
+	EnvKeyName          = "SERVICEBUS_KEY_NAME"
+	EnvKeyValue         = "SERVICEBUS_KEY_VALUE"
+	EnvQueueName        = "SERVICEBUS_QUEUE"
+)
+
+const namespaceHostSuffix = ".servicebus.windows.net"
+
+// Creates a client configured from environment variables. Either SERVICEBUS_CONNECTION_STRING
+// or SERVICEBUS_NAMESPACE, SERVICEBUS_KEY_NAME and SERVICEBUS_KEY_VALUE must be set.
+// The queue is named by SERVICEBUS_QUEUE or the EntityPath of the connection string.
+func NewClientFromEnvironment() (*QueueClient, error) {
+
+	if cs := os.Getenv(EnvConnectionString); cs != "" {
+		q, err := NewClientFromConnectionString(cs, os.Getenv(EnvQueueName))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s: %v", EnvConnectionString, err)
+		}
+		return q, nil
+	}
+
+	q := &QueueClient{
+		Namespace: os.Getenv(EnvNamespace),
+		KeyName:   os.Getenv(EnvKeyName),
+		KeyValue:  os.Getenv(EnvKeyValue),
+		QueueName: os.Getenv(EnvQueueName),
+	}
+
+	var missing []string
+	for _, name := range []string{EnvNamespace, EnvKeyName, EnvKeyValue, EnvQueueName} {
+		if os.Getenv(name) == "" {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("Either %s or %s must be set", EnvConnectionString, strings.Join(missing, ", "))
+	}
+
+	return q, nil
+}
+
+// Creates a client from a connection string as shown in the Azure portal, e.g.
+// Endpoint=sb://<yournamespace>.servicebus.windows.net/;SharedAccessKeyName=<keyname>;SharedAccessKey=<key>
+// queueName may be empty if the connection string has an EntityPath, which it overrides otherwise.
+func NewClientFromConnectionString(connectionString string, queueName string) (*QueueClient, error) {
+
+	q, err := parseConnectionString(connectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	if queueName != "" {
+		q.QueueName = queueName
+	}
+
+	if q.QueueName == "" {
+		return nil, fmt.Errorf("Queue name is missing, set EntityPath or pass it explicitly")
+	}
+
+	return q, nil
+}
+
+// Returns a client with the namespace, credentials and EntityPath of the connection string.
+func parseConnectionString(connectionString string) (*QueueClient, error) {
+
+	q := &QueueClient{}
+
+	for _, part := range strings.Split(connectionString, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Malformed part %q", part)
+		}
+
+		switch value := kv[1]; strings.TrimSpace(kv[0]) {
+		case "Endpoint":
+			u, err := url.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid Endpoint: %v", err)
+			}
+			if !strings.HasSuffix(u.Hostname(), namespaceHostSuffix) {
+				return nil, fmt.Errorf("Endpoint %s is not a %s namespace", value, namespaceHostSuffix)
+			}
+			q.Namespace = strings.TrimSuffix(u.Hostname(), namespaceHostSuffix)
+		case "SharedAccessKeyName":
+			q.KeyName = value
+		case "SharedAccessKey":
+			q.KeyValue = value
+		case "EntityPath":
+			q.QueueName = value
+		}
+	}
+
+	switch {
+	case q.Namespace == "":
+		return nil, fmt.Errorf("Endpoint is missing")
+	case q.KeyName == "":
+		return nil, fmt.Errorf("SharedAccessKeyName is missing")
+	case q.KeyValue == "":
+		return nil, fmt.Errorf("SharedAccessKey is missing")
+	}
+
+	return q, nil
+}
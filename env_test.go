@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_NewClientFromConnectionString(t *testing.T) {
+
+	cs := "Endpoint=sb://myns.servicebus.windows.net/;SharedAccessKeyName=RootManageSharedAccessKey;SharedAccessKey=abc=;EntityPath=orders"
+
+	q, err := NewClientFromConnectionString(cs, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if q.Namespace != "myns" || q.KeyName != "RootManageSharedAccessKey" || q.KeyValue != "abc=" || q.QueueName != "orders" {
+		t.Fatalf("Unexpected client %+v", q)
+	}
+
+	q, _ = NewClientFromConnectionString(cs, "payments")
+	if q.QueueName != "payments" {
+		t.Fatalf("Expected queue name payments but got %v", q.QueueName)
+	}
+}
+
+func Test_NewClientFromConnectionString_invalid(t *testing.T) {
+
+	cases := map[string]string{
+		"SharedAccessKeyName=key;SharedAccessKey=value":                                        "Endpoint is missing",
+		"Endpoint=sb://myns.servicebus.windows.net/;SharedAccessKey=value":                     "SharedAccessKeyName is missing",
+		"Endpoint=sb://myns.example.com/;SharedAccessKeyName=key;SharedAccessKey=value":        "is not a",
+		"Endpoint=sb://myns.servicebus.windows.net/;SharedAccessKeyName=key;SharedAccessKey=v": "Queue name is missing",
+		"Endpoint": "Malformed",
+	}
+
+	for cs, expected := range cases {
+		_, err := NewClientFromConnectionString(cs, "")
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected error containing %q for %q but got %v", expected, cs, err)
+		}
+	}
+}
+
+func Test_NewClientFromEnvironment(t *testing.T) {
+
+	t.Setenv(EnvConnectionString, "")
+	t.Setenv(EnvNamespace, "myns")
+	t.Setenv(EnvKeyName, "")
+	t.Setenv(EnvKeyValue, "")
+	t.Setenv(EnvQueueName, "orders")
+
+	_, err := NewClientFromEnvironment()
+	if err == nil || !strings.Contains(err.Error(), EnvKeyName+", "+EnvKeyValue) {
+		t.Fatalf("Expected error naming the missing variables but got %v", err)
+	}
+
+	t.Setenv(EnvKeyName, "key")
+	t.Setenv(EnvKeyValue, "value")
+
+	q, err := NewClientFromEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Namespace != "myns" || q.QueueName != "orders" {
+		t.Fatalf("Unexpected client %+v", q)
+	}
+
+	t.Setenv(EnvConnectionString, "Endpoint=sb://other.servicebus.windows.net/;SharedAccessKeyName=key;SharedAccessKey=value")
+
+	q, err = NewClientFromEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Namespace != "other" || q.QueueName != "orders" {
+		t.Fatalf("Expected connection string to take precedence but got %+v", q)
+	}
+}
@@ -1,6 +1,32 @@
 package queue
 
-import "fmt"
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Structured error detail returned by Service Bus in an error response body.
+type ErrorDetail struct {
+	// Error code reported by the service, e.g. 40100.
+	Code string
+
+	// Human readable description of the error.
+	Message string
+
+	// Name of the service exception mentioned in the description, e.g. MessageLockLostException.
+	Exception string
+
+	// Id of the failed request, useful when contacting Azure support.
+	TrackingId string
+}
+
+// Returned by operations called after QueueClient.Close.
+var ErrClientClosed = errors.New("Client is closed")
 
 type NoMessagesAvailableError struct {
 	Code int
@@ -11,6 +37,11 @@ func (e NoMessagesAvailableError) Error() string {
 	return "No messages available within the specified timeout period"
 }
 
+// Parses the structured error detail from the response body.
+func (e NoMessagesAvailableError) Detail() ErrorDetail {
+	return parseErrorDetail([]byte(e.Body))
+}
+
 type BadRequestError struct {
 	Code int
 	Body string
@@ -20,6 +51,11 @@ func (e BadRequestError) Error() string {
 	return "Bad createRequest"
 }
 
+// Parses the structured error detail from the response body.
+func (e BadRequestError) Detail() ErrorDetail {
+	return parseErrorDetail([]byte(e.Body))
+}
+
 type NotAuthorizedError struct {
 	Code int
 	Body string
@@ -29,6 +65,39 @@ func (e NotAuthorizedError) Error() string {
 	return "Authorization failure"
 }
 
+// Parses the structured error detail from the response body.
+func (e NotAuthorizedError) Detail() ErrorDetail {
+	return parseErrorDetail([]byte(e.Body))
+}
+
+type ForbiddenError struct {
+	Code int
+	Body string
+}
+
+func (e ForbiddenError) Error() string {
+	return "Forbidden, the quota is exceeded or the entity is disabled"
+}
+
+// Parses the structured error detail from the response body.
+func (e ForbiddenError) Detail() ErrorDetail {
+	return parseErrorDetail([]byte(e.Body))
+}
+
+type RequestTimeoutError struct {
+	Code int
+	Body string
+}
+
+func (e RequestTimeoutError) Error() string {
+	return "The service did not complete the request in time"
+}
+
+// Parses the structured error detail from the response body.
+func (e RequestTimeoutError) Detail() ErrorDetail {
+	return parseErrorDetail([]byte(e.Body))
+}
+
 type MessageDontExistError struct {
 	Code int
 	Body string
@@ -38,6 +107,11 @@ func (e MessageDontExistError) Error() string {
 	return "No message was found with the specified MessageId or LockToken."
 }
 
+// Parses the structured error detail from the response body.
+func (e MessageDontExistError) Detail() ErrorDetail {
+	return parseErrorDetail([]byte(e.Body))
+}
+
 type QueueDontExistError struct {
 	Code int
 	Body string
@@ -47,6 +121,11 @@ func (e QueueDontExistError) Error() string {
 	return "Specified queue or subscription does not exist"
 }
 
+// Parses the structured error detail from the response body.
+func (e QueueDontExistError) Detail() ErrorDetail {
+	return parseErrorDetail([]byte(e.Body))
+}
+
 type InternalError struct {
 	Code int
 	Body string
@@ -56,10 +135,111 @@ func (e InternalError) Error() string {
 	return "Internal Error"
 }
 
+// Parses the structured error detail from the response body.
+func (e InternalError) Detail() ErrorDetail {
+	return parseErrorDetail([]byte(e.Body))
+}
+
+// Returned for response statuses without a dedicated error type, e.g. 503.
+type UnknownStatusError struct {
+	Code int
+	Body string
+}
+
+func (e UnknownStatusError) Error() string {
+	return fmt.Sprintf("Unknown status %v with body %v", e.Code, e.Body)
+}
+
+// Parses the structured error detail from the response body.
+func (e UnknownStatusError) Detail() ErrorDetail {
+	return parseErrorDetail([]byte(e.Body))
+}
+
 func wrap(err error, message string) error {
 	if err == nil {
 		return nil
 	}
 
-	return fmt.Errorf(message + ": " + err.Error())
-}
\ No newline at end of file
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+var (
+	trackingIdPattern = regexp.MustCompile(`TrackingId:\s*([^,\s]+)`)
+	exceptionPattern  = regexp.MustCompile(`\b(\w+Exception)\b`)
+)
+
+// Parses the error detail from an XML or JSON error response body.
+// Bodies in an unknown format are used as the message as is.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/service-bus-error-codes
+func parseErrorDetail(body []byte) ErrorDetail {
+
+	detail := ErrorDetail{}
+
+	xmlBody := struct {
+		Code   string `xml:"Code"`
+		Detail string `xml:"Detail"`
+	}{}
+
+	jsonBody := struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}{}
+
+	if err := xml.Unmarshal(body, &xmlBody); err == nil {
+		detail.Code = xmlBody.Code
+		detail.Message = xmlBody.Detail
+	} else if err := json.Unmarshal(body, &jsonBody); err == nil {
+		detail.Code = jsonBody.Error.Code
+		detail.Message = jsonBody.Error.Message
+	} else {
+		detail.Message = strings.TrimSpace(string(body))
+	}
+
+	if m := trackingIdPattern.FindStringSubmatch(detail.Message); m != nil {
+		detail.TrackingId = m[1]
+	}
+
+	if m := exceptionPattern.FindStringSubmatch(detail.Message); m != nil {
+		detail.Exception = m[1]
+	}
+
+	return detail
+}
+
+// Returned when a response body exceeds QueueClient.MaxResponseBodySize.
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("Response body exceeds the limit of %v bytes", e.Limit)
+}
+
+// Returned by settlement operations called with a message which can't be settled,
+// without making a request.
+type InvalidLockError struct {
+	MessageId string
+	Reason    string
+}
+
+func (e InvalidLockError) Error() string {
+	return fmt.Sprintf("Cannot settle message %q: %s", e.MessageId, e.Reason)
+}
+
+// Checks that the message carries a lock which can still be settled.
+func validateLock(msg *Message, now time.Time) error {
+
+	switch {
+	case msg.Id == "":
+		return InvalidLockError{Reason: "message has no Id"}
+	case msg.LockToken == "":
+		return InvalidLockError{MessageId: msg.Id, Reason: "message has no LockToken, was it received in peek-lock mode?"}
+	case !msg.LockedUntilUtc.IsZero() && !now.Before(msg.LockedUntilUtc):
+		return InvalidLockError{MessageId: msg.Id, Reason: "lock expired at " + msg.LockedUntilUtc.Format(time.RFC3339)}
+	}
+
+	return nil
+}
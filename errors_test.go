@@ -0,0 +1,128 @@
+package queue
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_parseErrorDetail(t *testing.T) {
+
+	tests := []struct {
+		body     string
+		expected ErrorDetail
+	}{
+		{
+			"<Error><Code>410</Code><Detail>The lock supplied is invalid. Microsoft.ServiceBus.Messaging.MessageLockLostException. TrackingId:7f3b1b3c-4d6e-4a8f-9b55-2d5b5f9f8c11_G2, SystemTracker:test:Queue:test, Timestamp:2018-02-22T10:03:56</Detail></Error>",
+			ErrorDetail{
+				Code:       "410",
+				Message:    "The lock supplied is invalid. Microsoft.ServiceBus.Messaging.MessageLockLostException. TrackingId:7f3b1b3c-4d6e-4a8f-9b55-2d5b5f9f8c11_G2, SystemTracker:test:Queue:test, Timestamp:2018-02-22T10:03:56",
+				Exception:  "MessageLockLostException",
+				TrackingId: "7f3b1b3c-4d6e-4a8f-9b55-2d5b5f9f8c11_G2",
+			},
+		},
+		{
+			`{"error":{"code":"40100","message":"Unauthorized. TrackingId:abc, SystemTracker:test"}}`,
+			ErrorDetail{
+				Code:       "40100",
+				Message:    "Unauthorized. TrackingId:abc, SystemTracker:test",
+				TrackingId: "abc",
+			},
+		},
+		{
+			"Service unavailable ",
+			ErrorDetail{Message: "Service unavailable"},
+		},
+	}
+
+	for _, test := range tests {
+		detail := parseErrorDetail([]byte(test.body))
+
+		if detail != test.expected {
+			t.Fatalf("Expected detail %+v but got %+v", test.expected, detail)
+		}
+	}
+}
+
+func Test_handleStatusCode_detail(t *testing.T) {
+
+	resp := http.Response{
+		StatusCode: 401,
+		Body:       ioutil.NopCloser(bytes.NewBufferString("<Error><Code>401</Code><Detail>InvalidSignature. TrackingId:abc</Detail></Error>")),
+	}
+
+	err, ok := handleStatusCode(&resp).(NotAuthorizedError)
+
+	if !ok {
+		t.Fatal("Expected error type NotAuthorizedError")
+	}
+
+	if err.Detail().TrackingId != "abc" {
+		t.Fatalf("Expected TrackingId %s but got %s", "abc", err.Detail().TrackingId)
+	}
+}
+
+func Test_wrap(t *testing.T) {
+
+	if wrap(nil, "message") != nil {
+		t.Fatal("Expected nil error to stay nil")
+	}
+
+	err := wrap(stringError("100%"), "Request failed")
+
+	if err.Error() != "Request failed: 100%" {
+		t.Fatalf("Expected error %s but got %s", "Request failed: 100%", err.Error())
+	}
+}
+
+type stringError string
+
+func (e stringError) Error() string {
+	return string(e)
+}
+
+func Test_validateLock(t *testing.T) {
+
+	now := time.Date(2018, 1, 1, 1, 1, 1, 0, time.UTC)
+
+	cases := []struct {
+		msg   Message
+		valid bool
+	}{
+		{Message{Id: "abc", LockToken: "lock"}, true},
+		{Message{Id: "abc", LockToken: "lock", LockedUntilUtc: now.Add(time.Second)}, true},
+		{Message{Id: "abc", LockToken: "lock", LockedUntilUtc: now.Add(-time.Second)}, false},
+		// expired at the instant of the expiry, as IsLockExpired reports it
+		{Message{Id: "abc", LockToken: "lock", LockedUntilUtc: now}, false},
+		{Message{Id: "abc"}, false},
+		{Message{LockToken: "lock"}, false},
+	}
+
+	for _, c := range cases {
+		err := validateLock(&c.msg, now)
+		if (err == nil) != c.valid {
+			t.Fatalf("Expected valid=%v for %+v but got %v", c.valid, c.msg, err)
+		}
+		if err != nil {
+			if _, ok := err.(InvalidLockError); !ok {
+				t.Fatalf("Expected error type InvalidLockError but got %T", err)
+			}
+		}
+	}
+}
+
+func Test_DeleteMessage_invalidLock(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		t.Fatalf("Expected no request to be made")
+		return nil, nil
+	}))
+
+	if err := q.DeleteMessage(NewMessage([]byte("hello"))); err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
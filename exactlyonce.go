@@ -0,0 +1,55 @@
+package queue
+
+// Receives the next message and processes it with handler at most once per MessageId
+// within the store's window:
+//
+//   - A message whose id is already marked in the store is completed without calling handler.
+//   - If handler fails, the message is unlocked for redelivery and the handler error returned.
+//   - If handler succeeds, the id is marked in the store and then the message is completed.
+//
+// Failure windows: if the process dies or marking fails after handler succeeded, the message
+// is redelivered and handled again, so handler side effects must tolerate that narrow window.
+// If completion fails after marking, the redelivered message is recognized as processed and
+// completed without calling handler. Messages without a MessageId are processed at least once.
+//
+// Returns NoMessagesAvailableError if there was nothing to process.
+func ProcessExactlyOnce(q *QueueClient, store DedupeStore, handler func(msg *Message) error) error {
+
+	msg, err := q.GetMessage()
+	if err != nil {
+		return err
+	}
+
+	if msg.Id != "" {
+		seen, err := store.Seen(msg.Id)
+		if err != nil {
+			q.unlockAfterFailure(msg)
+			return wrap(err, "Dedupe store lookup failed")
+		}
+
+		if seen {
+			logger.DebugFields("Completing already processed message", q.logFields("receive", msg))
+			return q.DeleteMessage(msg)
+		}
+	}
+
+	if err := handler(msg); err != nil {
+		q.unlockAfterFailure(msg)
+		return err
+	}
+
+	if msg.Id != "" {
+		if err := store.Mark(msg.Id); err != nil {
+			q.unlockAfterFailure(msg)
+			return wrap(err, "Dedupe store update failed")
+		}
+	}
+
+	return q.DeleteMessage(msg)
+}
+
+func (q *QueueClient) unlockAfterFailure(msg *Message) {
+	if err := q.UnlockMessage(msg); err != nil {
+		q.logError("Unlocking message failed", "abandon", msg, err)
+	}
+}
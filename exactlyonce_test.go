@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_ProcessExactlyOnce(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	ids := []string{"1", "1", "1"}
+	var settled []string
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method != "POST" {
+			settled = append(settled, req.Method)
+			return respondWith(200, "")(req)
+		}
+
+		if len(ids) == 0 {
+			return respondWith(204, "")(req)
+		}
+		id := ids[0]
+		ids = ids[1:]
+
+		resp, _ := respondWith(200, "hello")(req)
+		resp.Header.Set(headerBrokerProperties, `{"MessageId":"`+id+`","LockToken":"lock"}`)
+		return resp, nil
+	}))
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test"}
+	store := NewMemoryDedupeStore(10, time.Hour)
+
+	calls := 0
+	failing := func(msg *Message) error { calls++; return errors.New("failed") }
+	succeeding := func(msg *Message) error { calls++; return nil }
+
+	tests := []struct {
+		handler func(*Message) error
+		fails   bool
+		calls   int
+		settled string
+	}{
+		{failing, true, 1, "PUT"},
+		{succeeding, false, 2, "DELETE"},
+		{succeeding, false, 2, "DELETE"},
+	}
+
+	for i, test := range tests {
+		err := ProcessExactlyOnce(cli, store, test.handler)
+
+		if (err != nil) != test.fails {
+			t.Fatalf("Unexpected error %v in run %v", err, i)
+		}
+
+		if calls != test.calls {
+			t.Fatalf("Expected %v handler calls after run %v but got %v", test.calls, i, calls)
+		}
+
+		if settled[len(settled)-1] != test.settled {
+			t.Fatalf("Expected message to be settled with %s in run %v but got %s", test.settled, i, settled[len(settled)-1])
+		}
+	}
+
+	if _, ok := ProcessExactlyOnce(cli, store, succeeding).(NoMessagesAvailableError); !ok {
+		t.Fatal("Expected NoMessagesAvailableError on empty queue")
+	}
+}
@@ -0,0 +1,49 @@
+package queue
+
+import "sync"
+
+// Sends a message to several queues concurrently, e.g. for simple replication
+// without re-architecting around a topic.
+type FanOutSender struct {
+	// Queues to send to. Any Sender can be used, e.g. a storage queue.
+	Targets []Sender
+
+	// Optional hook adjusting the message sent to a target, e.g. to set a
+	// per-target Label. It receives the target index and a copy of the message.
+	Override func(target int, msg *Message)
+}
+
+// Result of sending to one target.
+type FanOutResult struct {
+	// Index of the target in Targets.
+	Target int
+
+	// Error returned by the target, nil on success.
+	Err error
+}
+
+// Sends the message to all targets concurrently and returns one result per
+// target, in the order of Targets.
+func (s *FanOutSender) Send(msg *Message) []FanOutResult {
+
+	results := make([]FanOutResult, len(s.Targets))
+
+	var wg sync.WaitGroup
+	for i, target := range s.Targets {
+
+		m := msg
+		if s.Override != nil {
+			m = msg.Clone()
+			s.Override(i, m)
+		}
+
+		wg.Add(1)
+		go func(i int, target Sender, m *Message) {
+			defer wg.Done()
+			results[i] = FanOutResult{Target: i, Err: target.SendMessage(m)}
+		}(i, target, m)
+	}
+	wg.Wait()
+
+	return results
+}
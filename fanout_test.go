@@ -0,0 +1,73 @@
+package queue
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type recordingSender struct {
+	mu   sync.Mutex
+	sent []*Message
+	err  error
+}
+
+func (s *recordingSender) SendMessage(msg *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, msg)
+	return s.err
+}
+
+func Test_FanOutSender(t *testing.T) {
+
+	targets := []*recordingSender{{}, {err: errors.New("failed")}, {}}
+
+	s := &FanOutSender{
+		Targets: []Sender{targets[0], targets[1], targets[2]},
+		Override: func(target int, msg *Message) {
+			msg.Label = "target-" + strconv.Itoa(target)
+		},
+	}
+
+	msg := NewMessage([]byte("hello"))
+	results := s.Send(msg)
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results but got %v", len(results))
+	}
+
+	for i, r := range results {
+		if r.Target != i {
+			t.Fatalf("Expected result for target %v but got %v", i, r.Target)
+		}
+
+		if (r.Err != nil) != (i == 1) {
+			t.Fatalf("Unexpected result %v for target %v", r.Err, i)
+		}
+
+		if len(targets[i].sent) != 1 || targets[i].sent[0].Label != "target-"+strconv.Itoa(i) {
+			t.Fatalf("Expected target %v to receive its own copy of the message", i)
+		}
+	}
+
+	if msg.Label != "" {
+		t.Fatal("Expected original message not to be modified")
+	}
+}
+
+func Test_Message_Clone(t *testing.T) {
+
+	msg := NewMessage([]byte("hello"))
+	msg.Properties.Set("Prop1", "Value1")
+
+	c := msg.Clone()
+	c.Body[0] = 'j'
+	c.Properties.Set("Prop1", "Value2")
+
+	if string(msg.Body) != "hello" || msg.Properties.Get("Prop1") != "Value1" {
+		t.Fatal("Expected clone not to share body or properties with the original")
+	}
+}
@@ -0,0 +1,39 @@
+package queue
+
+import "fmt"
+
+// Minimal length of a signing key in FIPS mode, as FIPS 140 requires HMAC keys of at least 112 bits.
+const fipsMinKeyLength = 14
+
+// Checks that the client's configuration only uses FIPS 140 validated cryptography
+// if RequireFIPS is set. The secondary key is checked too, as requests are resigned
+// with it once the primary key is rejected.
+func (q *QueueClient) checkFIPS() error {
+
+	if !q.RequireFIPS {
+		return nil
+	}
+
+	q.credMu.RLock()
+	keyValue, secondaryKeyValue := q.KeyValue, q.SecondaryKeyValue
+	q.credMu.RUnlock()
+
+	return validateFIPS(fipsEnabled(), keyValue, secondaryKeyValue)
+}
+
+func validateFIPS(runtimeEnabled bool, keyValue string, secondaryKeyValue string) error {
+
+	if !runtimeEnabled {
+		return fmt.Errorf("FIPS mode required but the Go cryptographic module is not in FIPS 140 mode, build with Go 1.24+ and run with GODEBUG=fips140=on")
+	}
+
+	if len(keyValue) < fipsMinKeyLength {
+		return fmt.Errorf("FIPS mode requires SAS keys of at least %d bytes", fipsMinKeyLength)
+	}
+
+	if secondaryKeyValue != "" && len(secondaryKeyValue) < fipsMinKeyLength {
+		return fmt.Errorf("FIPS mode requires SAS keys of at least %d bytes, the secondary key is shorter", fipsMinKeyLength)
+	}
+
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build go1.24
+
+package queue
+
+import "crypto/fips140"
+
+// Reports whether the Go cryptographic module runs in FIPS 140 mode.
+func fipsEnabled() bool {
+	return fips140.Enabled()
+}
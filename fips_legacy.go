@@ -0,0 +1,8 @@
+//go:build !go1.24
+
+package queue
+
+// Go releases before 1.24 have no FIPS 140 validated cryptographic module.
+func fipsEnabled() bool {
+	return false
+}
@@ -0,0 +1,51 @@
+package queue
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_validateFIPS(t *testing.T) {
+
+	if err := validateFIPS(true, "0123456789abcdef", ""); err != nil {
+		t.Fatalf("Expected valid configuration but got %v", err)
+	}
+
+	if err := validateFIPS(true, "0123456789abcdef", "fedcba9876543210"); err != nil {
+		t.Fatalf("Expected valid configuration but got %v", err)
+	}
+
+	if err := validateFIPS(true, "short", ""); err == nil {
+		t.Fatalf("Expected error for a short key")
+	}
+
+	if err := validateFIPS(true, "0123456789abcdef", "short"); err == nil {
+		t.Fatalf("Expected error for a short secondary key")
+	}
+
+	if err := validateFIPS(false, "0123456789abcdef", ""); err == nil {
+		t.Fatalf("Expected error without FIPS runtime")
+	}
+}
+
+func Test_RequireFIPS(t *testing.T) {
+
+	if fipsEnabled() {
+		t.Skip("running in FIPS 140 mode")
+	}
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		t.Fatalf("Expected no request to be made")
+		return nil, nil
+	}))
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test", KeyValue: "0123456789abcdef", RequireFIPS: true}
+
+	err := cli.SendMessage(NewMessage([]byte("hello")))
+	if err == nil || !strings.Contains(err.Error(), "FIPS") {
+		t.Fatalf("Expected FIPS error but got %v", err)
+	}
+}
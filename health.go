@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Health of a client as reported by its health handlers.
+type Health struct {
+	// Whether the client has been closed.
+	Closed bool `json:"closed"`
+
+	// Time of the last request the service responded to with a success status.
+	LastSuccess time.Time `json:"lastSuccess"`
+
+	// Time of the last successful receive request, including empty polls.
+	LastReceive time.Time `json:"lastReceive"`
+
+	// Number of received messages not settled yet, if the client has a Tracker.
+	InFlight int `json:"inFlight"`
+}
+
+// Returns the current health of the client.
+func (q *QueueClient) Health() Health {
+
+	h := Health{Closed: q.checkClosed() != nil}
+
+	q.healthMu.Lock()
+	h.LastSuccess = q.lastSuccess
+	h.LastReceive = q.lastReceive
+	q.healthMu.Unlock()
+
+	if q.Tracker != nil {
+		h.InFlight = q.Tracker.InFlight()
+	}
+
+	return h
+}
+
+// Returns a handler for liveness probes responding 200 until the client is closed
+// and 503 afterwards. The body is the JSON encoded Health.
+func (q *QueueClient) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := q.Health()
+		writeHealth(w, h, !h.Closed)
+	})
+}
+
+// Returns a handler for readiness probes responding 200 if the client isn't closed
+// and the service responded successfully within maxIdle, and 503 otherwise.
+// maxIdle should exceed Timeout so that empty long polls keep the client ready.
+// The body is the JSON encoded Health.
+func (q *QueueClient) ReadinessHandler(maxIdle time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := q.Health()
+		writeHealth(w, h, !h.Closed && time.Since(h.LastSuccess) <= maxIdle)
+	})
+}
+
+// Records a response of the service for the health handlers.
+func (q *QueueClient) recordHealth(operation string, statusCode int) {
+
+	if statusCode < 200 || statusCode >= 300 {
+		return
+	}
+
+	now := time.Now()
+
+	q.healthMu.Lock()
+	defer q.healthMu.Unlock()
+
+	q.lastSuccess = now
+	if operation == "receive" {
+		q.lastReceive = now
+	}
+}
+
+func writeHealth(w http.ResponseWriter, h Health, healthy bool) {
+
+	w.Header().Set("Content-Type", "application/json")
+	if healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(h)
+}
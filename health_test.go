@@ -0,0 +1,67 @@
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_ReadinessHandler(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test", Tracker: NewMemoryTracker()}
+	handler := cli.ReadinessHandler(time.Minute)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 before any request but got %v", rec.Code)
+	}
+
+	SetHttpClient(respondWith(204, ""))
+	cli.GetMessage()
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 after an empty poll but got %v", rec.Code)
+	}
+
+	h := Health{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.LastReceive.IsZero() || h.LastSuccess.IsZero() || h.Closed {
+		t.Fatalf("Unexpected health %+v", h)
+	}
+
+	cli.Close()
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 after Close but got %v", rec.Code)
+	}
+}
+
+func Test_LivenessHandler(t *testing.T) {
+
+	cli := &QueueClient{}
+
+	rec := httptest.NewRecorder()
+	cli.LivenessHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/live", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 but got %v", rec.Code)
+	}
+
+	cli.Close()
+
+	rec = httptest.NewRecorder()
+	cli.LivenessHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/live", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 after Close but got %v", rec.Code)
+	}
+}
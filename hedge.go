@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+type receiveResult struct {
+	msg *Message
+	err error
+}
+
+// Issues a second receive if the first one hasn't returned within HedgeDelay
+// and returns the first message received, even if it failed verification or
+// decoding. The losing request is cancelled, and if it received a message
+// regardless, that message is unlocked.
+func (q *QueueClient) getMessageHedged(ctx context.Context, timeout int) (*Message, error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan receiveResult, 2)
+	poll := func() {
+		msg, err := q.getMessage(ctx, timeout)
+		results <- receiveResult{msg, err}
+	}
+
+	go poll()
+	pending := 1
+
+	timer := time.NewTimer(q.HedgeDelay)
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		return r.msg, r.err
+	case <-timer.C:
+		logger.DebugFields("Hedging receive after "+q.HedgeDelay.String(), q.logFields("receive", nil))
+		go poll()
+		pending++
+	}
+
+	var r receiveResult
+	for pending > 0 {
+		r = <-results
+		pending--
+		if r.locked() != nil {
+			break
+		}
+	}
+
+	if pending > 0 {
+		go func() {
+			if msg := (<-results).locked(); msg != nil {
+				if err := q.UnlockMessage(msg); err != nil {
+					q.logError("Unlocking hedged message failed", "abandon", msg, err)
+				}
+			}
+		}()
+	}
+
+	return r.msg, r.err
+}
+
+// Returns the message locked by the receive, also if it failed verification or decoding.
+func (r receiveResult) locked() *Message {
+
+	switch err := r.err.(type) {
+	case nil:
+		return r.msg
+	case SignatureError:
+		return err.Message
+	case TransformError:
+		return err.Message
+	case SchemaError:
+		return err.Message
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package queue
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_getMessageHedged_slowFirst(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	var mu sync.Mutex
+	polls := 0
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		polls++
+		first := polls == 1
+		mu.Unlock()
+
+		if first {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		return respondWith(200, "hello")(req)
+	}))
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test", HedgeDelay: 10 * time.Millisecond}
+
+	msg, err := cli.GetMessage()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(msg.Body) != "hello" {
+		t.Fatalf("Expected body %s but got %s", "hello", string(msg.Body))
+	}
+}
+
+// Signals settled messages, telling when a settlement on another goroutine is done.
+type settledSignal chan *Message
+
+func (s settledSignal) Received(msg *Message)                 {}
+func (s settledSignal) Settled(msg *Message, outcome Outcome) { s <- msg }
+func (s settledSignal) InFlight() int                         { return 0 }
+func (s settledSignal) OldestLockAge() time.Duration          { return 0 }
+
+func Test_getMessageHedged_unlocksLoser(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	release := make(chan struct{})
+	unlocked := make(chan string, 1)
+
+	var mu sync.Mutex
+	polls := 0
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "PUT" {
+			unlocked <- req.URL.Path
+			return respondWith(200, "")(req)
+		}
+
+		mu.Lock()
+		polls++
+		poll := polls
+		mu.Unlock()
+
+		if poll == 1 {
+			<-release
+		}
+
+		resp, _ := respondWith(200, "hello")(req)
+		resp.Header.Set(headerBrokerProperties, `{"MessageId":"`+strconv.Itoa(poll)+`","LockToken":"lock"}`)
+		return resp, nil
+	}))
+
+	settled := make(settledSignal, 1)
+	cli := &QueueClient{Namespace: "test", QueueName: "test", HedgeDelay: 10 * time.Millisecond, Tracker: settled}
+
+	msg, err := cli.GetMessage()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Id != "2" {
+		t.Fatalf("Expected message from the hedged request but got %s", msg.Id)
+	}
+
+	close(release)
+
+	select {
+	case path := <-unlocked:
+		if path != "/test/messages/1/lock" {
+			t.Fatalf("Expected losing message to be unlocked but got %s", path)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected losing message to be unlocked")
+	}
+
+	// don't leave the unlock running into tests which replace the package loggers
+	select {
+	case <-settled:
+	case <-time.After(time.Second):
+		t.Fatal("Expected unlock to complete")
+	}
+}
+
+func Test_getMessageHedged_fast(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	polls := 0
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		polls++
+		return respondWith(204, "")(req)
+	}))
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test", HedgeDelay: time.Second}
+
+	if _, err := cli.GetMessage(); err == nil {
+		t.Fatal("Expected NoMessagesAvailableError")
+	}
+
+	if polls != 1 {
+		t.Fatalf("Expected a single request but got %v", polls)
+	}
+}
+
+func Test_getMessageHedged_invalidMessages(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	release := make(chan struct{})
+	unlocked := make(chan string, 1)
+
+	var mu sync.Mutex
+	polls := 0
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "PUT" {
+			unlocked <- req.URL.Path
+			return respondWith(200, "")(req)
+		}
+
+		mu.Lock()
+		polls++
+		poll := polls
+		mu.Unlock()
+
+		if poll == 1 {
+			<-release
+		}
+
+		// unsigned, so both messages fail verification
+		resp, _ := respondWith(200, "hello")(req)
+		resp.Header.Set(headerBrokerProperties, `{"MessageId":"`+strconv.Itoa(poll)+`","LockToken":"lock"}`)
+		return resp, nil
+	}))
+
+	settled := make(settledSignal, 1)
+	cli := &QueueClient{
+		Namespace:     "test",
+		QueueName:     "test",
+		HedgeDelay:    10 * time.Millisecond,
+		SignatureKeys: staticKeys{},
+		Tracker:       settled,
+	}
+
+	_, err := cli.GetMessage()
+
+	serr, ok := err.(SignatureError)
+	if !ok || serr.Message.Id != "2" {
+		t.Fatalf("Expected SignatureError for the hedged message but got %v", err)
+	}
+
+	close(release)
+
+	select {
+	case path := <-unlocked:
+		if path != "/test/messages/1/lock" {
+			t.Fatalf("Expected losing message to be unlocked but got %s", path)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected losing message to be unlocked")
+	}
+
+	select {
+	case <-settled:
+	case <-time.After(time.Second):
+		t.Fatal("Expected unlock to complete")
+	}
+}
@@ -0,0 +1,301 @@
+package queue
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Content-Type of bodies wrapped in a JOSE compact serialization.
+const ContentTypeJose = "application/jose"
+
+// JOSE token kinds produced by JoseEnvelope.
+const (
+	// Signed with HS256 ([]byte key) or EdDSA (ed25519 key).
+	JoseJWS = "JWS"
+
+	// Encrypted with direct A256GCM using a 32 byte key.
+	JoseJWE = "JWE"
+)
+
+// Returned when a JOSE token is malformed or fails verification or decryption.
+var ErrInvalidJose = errors.New("Invalid JOSE token")
+
+// Wraps message bodies in JWS or JWE compact tokens and unwraps them,
+// for services standardizing on JOSE for payload security.
+// The original Content-Type travels in the token's cty header.
+// JoseEnvelope is a BodyTransform, see QueueClient.Transforms.
+type JoseEnvelope struct {
+	// JoseJWS or JoseJWE.
+	Kind string
+
+	// Id of the key wrapping bodies, sent in the kid header.
+	KeyId string
+
+	// Key wrapping bodies: []byte or ed25519.PrivateKey for JWS, 32 byte []byte for JWE.
+	Key interface{}
+
+	// Resolves the kid header of received tokens to the key unwrapping them:
+	// []byte or ed25519.PublicKey for JWS, 32 byte []byte for JWE.
+	Keys SignatureKeyResolver
+
+	// Rejects received messages which aren't tokens with ErrInvalidJose, so consumers
+	// relying on JOSE can't be sent unsigned or unencrypted messages. Otherwise such
+	// messages are passed through, e.g. while producers are migrated.
+	RequireToken bool
+}
+
+type joseHeader struct {
+	Alg string `json:"alg"`
+	Enc string `json:"enc,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Cty string `json:"cty,omitempty"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// Replaces the body of the message with a token wrapping it.
+func (e *JoseEnvelope) Encode(msg *Message) error {
+
+	var token string
+	var err error
+
+	switch e.Kind {
+	case JoseJWS:
+		token, err = e.sign(msg.Body, msg.ContentType)
+	case JoseJWE:
+		token, err = e.encrypt(msg.Body, msg.ContentType)
+	default:
+		err = fmt.Errorf("Unknown JOSE kind %q", e.Kind)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	msg.Body = []byte(token)
+	msg.ContentType = ContentTypeJose
+	return nil
+}
+
+// Replaces a token body of the message with the payload it wraps. Messages whose
+// Content-Type isn't ContentTypeJose are left as they are unless RequireToken is set.
+// Returns ErrInvalidJose if the token doesn't verify or decrypt.
+func (e *JoseEnvelope) Decode(msg *Message) error {
+
+	if msg.ContentType != ContentTypeJose {
+		if e.RequireToken {
+			return ErrInvalidJose
+		}
+		return nil
+	}
+
+	if e.Keys == nil {
+		return errors.New("JoseEnvelope has no Keys to unwrap tokens with")
+	}
+
+	parts := strings.Split(string(msg.Body), ".")
+
+	var payload []byte
+	var header joseHeader
+	var err error
+
+	switch len(parts) {
+	case 3:
+		payload, header, err = e.verify(parts)
+	case 5:
+		payload, header, err = e.decrypt(parts)
+	default:
+		err = ErrInvalidJose
+	}
+
+	if err != nil {
+		return err
+	}
+
+	msg.Body = payload
+	msg.ContentType = header.Cty
+	return nil
+}
+
+func (e *JoseEnvelope) sign(payload []byte, contentType string) (string, error) {
+
+	header := joseHeader{Kid: e.KeyId, Cty: contentType}
+	switch e.Key.(type) {
+	case []byte:
+		header.Alg = "HS256"
+	case ed25519.PrivateKey:
+		header.Alg = "EdDSA"
+	default:
+		return "", fmt.Errorf("Unsupported JWS key type %T", e.Key)
+	}
+
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	input := b64.EncodeToString(h) + "." + b64.EncodeToString(payload)
+
+	var sig []byte
+	switch key := e.Key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(key, []byte(input))
+	}
+
+	return input + "." + b64.EncodeToString(sig), nil
+}
+
+func (e *JoseEnvelope) verify(parts []string) ([]byte, joseHeader, error) {
+
+	header, err := parseJoseHeader(parts[0])
+	if err != nil {
+		return nil, header, err
+	}
+
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return nil, header, ErrInvalidJose
+	}
+
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, header, ErrInvalidJose
+	}
+
+	key, err := e.Keys.ResolveKey(header.Kid)
+	if err != nil {
+		return nil, header, wrap(err, "Resolving JWS key failed")
+	}
+
+	input := []byte(parts[0] + "." + parts[1])
+
+	valid := false
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write(input)
+		valid = header.Alg == "HS256" && hmac.Equal(sig, mac.Sum(nil))
+	case ed25519.PublicKey:
+		valid = header.Alg == "EdDSA" && ed25519.Verify(key, input, sig)
+	}
+
+	if !valid {
+		return nil, header, ErrInvalidJose
+	}
+
+	return payload, header, nil
+}
+
+func (e *JoseEnvelope) encrypt(payload []byte, contentType string) (string, error) {
+
+	key, ok := e.Key.([]byte)
+	if !ok || len(key) != 32 {
+		return "", fmt.Errorf("JWE requires a 32 byte key")
+	}
+
+	h, err := json.Marshal(joseHeader{Alg: "dir", Enc: "A256GCM", Kid: e.KeyId, Cty: contentType})
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+
+	iv := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+
+	header := b64.EncodeToString(h)
+	sealed := gcm.Seal(nil, iv, payload, []byte(header))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	// direct encryption has no encrypted key
+	return header + ".." + b64.EncodeToString(iv) + "." + b64.EncodeToString(ciphertext) + "." + b64.EncodeToString(tag), nil
+}
+
+func (e *JoseEnvelope) decrypt(parts []string) ([]byte, joseHeader, error) {
+
+	header, err := parseJoseHeader(parts[0])
+	if err != nil {
+		return nil, header, err
+	}
+
+	if header.Alg != "dir" || header.Enc != "A256GCM" || parts[1] != "" {
+		return nil, header, ErrInvalidJose
+	}
+
+	key, err := e.Keys.ResolveKey(header.Kid)
+	if err != nil {
+		return nil, header, wrap(err, "Resolving JWE key failed")
+	}
+
+	k, ok := key.([]byte)
+	if !ok || len(k) != 32 {
+		return nil, header, fmt.Errorf("JWE requires a 32 byte key")
+	}
+
+	gcm, err := newGCM(k)
+	if err != nil {
+		return nil, header, err
+	}
+
+	var decoded [3][]byte
+	for i, part := range parts[2:] {
+		if decoded[i], err = b64.DecodeString(part); err != nil {
+			return nil, header, ErrInvalidJose
+		}
+	}
+
+	iv, ciphertext, tag := decoded[0], decoded[1], decoded[2]
+	if len(iv) != gcm.NonceSize() {
+		return nil, header, ErrInvalidJose
+	}
+
+	payload, err := gcm.Open(nil, iv, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		return nil, header, ErrInvalidJose
+	}
+
+	return payload, header, nil
+}
+
+func parseJoseHeader(encoded string) (joseHeader, error) {
+
+	header := joseHeader{}
+
+	h, err := b64.DecodeString(encoded)
+	if err != nil {
+		return header, ErrInvalidJose
+	}
+
+	if err := json.Unmarshal(h, &header); err != nil {
+		return header, ErrInvalidJose
+	}
+
+	return header, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
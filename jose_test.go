@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"strings"
+	"testing"
+)
+
+func Test_JoseEnvelope(t *testing.T) {
+
+	public, private, _ := ed25519.GenerateKey(nil)
+	aesKey := bytes.Repeat([]byte{7}, 32)
+
+	tests := []struct {
+		envelope JoseEnvelope
+		parts    int
+	}{
+		{JoseEnvelope{Kind: JoseJWS, KeyId: "hs", Key: []byte("secret"), Keys: staticKeys{"hs": []byte("secret")}}, 3},
+		{JoseEnvelope{Kind: JoseJWS, KeyId: "ed", Key: private, Keys: staticKeys{"ed": public}}, 3},
+		{JoseEnvelope{Kind: JoseJWE, KeyId: "aes", Key: aesKey, Keys: staticKeys{"aes": aesKey}}, 5},
+	}
+
+	for _, test := range tests {
+		msg := NewMessage([]byte(`{"order":42}`))
+		msg.ContentType = "application/json"
+
+		if err := test.envelope.Encode(msg); err != nil {
+			t.Fatal(err)
+		}
+
+		if msg.ContentType != ContentTypeJose || len(strings.Split(string(msg.Body), ".")) != test.parts {
+			t.Fatalf("Expected %v token but got %s", test.envelope.Kind, msg.Body)
+		}
+
+		if test.envelope.Kind == JoseJWE && bytes.Contains(msg.Body, []byte("order")) {
+			t.Fatalf("Expected encrypted body but got %s", msg.Body)
+		}
+
+		tampered := msg.Clone()
+		// change the leading bits of the signature or tag
+		i := bytes.LastIndexByte(tampered.Body, '.') + 1
+		if tampered.Body[i] == 'A' {
+			tampered.Body[i] = 'B'
+		} else {
+			tampered.Body[i] = 'A'
+		}
+		if err := test.envelope.Decode(tampered); err != ErrInvalidJose {
+			t.Fatalf("Expected tampered %v token to be rejected but got %v", test.envelope.Kind, err)
+		}
+
+		if err := test.envelope.Decode(msg); err != nil {
+			t.Fatal(err)
+		}
+
+		if string(msg.Body) != `{"order":42}` || msg.ContentType != "application/json" {
+			t.Fatalf("Expected original body and content type but got %s %s", msg.Body, msg.ContentType)
+		}
+	}
+}
+
+func Test_JoseEnvelope_Decode_skipsAndValidates(t *testing.T) {
+
+	plain := NewMessage([]byte(`{"order":42}`))
+	plain.ContentType = "application/json"
+
+	if err := (&JoseEnvelope{}).Decode(plain); err != nil || string(plain.Body) != `{"order":42}` {
+		t.Fatalf("Expected message without a token to be left as is but got %v %s", err, plain.Body)
+	}
+
+	envelope := &JoseEnvelope{Kind: JoseJWS, KeyId: "hs", Key: []byte("secret")}
+	msg := NewMessage([]byte(`{"order":42}`))
+	if err := envelope.Encode(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := envelope.Decode(msg); err == nil {
+		t.Fatal("Expected error decoding without Keys")
+	}
+
+	envelope.RequireToken = true
+	if err := envelope.Decode(plain); err != ErrInvalidJose {
+		t.Fatalf("Expected error %v for a message without a token but got %v", ErrInvalidJose, err)
+	}
+}
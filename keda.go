@@ -0,0 +1,38 @@
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Queue length as served by ScalerMetricsHandler.
+type ScalerMetrics struct {
+	QueueName          string `json:"queueName"`
+	MessageCount       int64  `json:"messageCount"`
+	ActiveMessageCount int64  `json:"activeMessageCount"`
+}
+
+// Returns a handler serving the queue length as JSON for KEDA's metrics-api scaler,
+// e.g. {"queueName":"orders","messageCount":12,"activeMessageCount":10}.
+// Configure the scaler with valueLocation "activeMessageCount". The counts are fetched
+// on every request; failures are answered with 503.
+//
+// For more information see https://keda.sh/docs/latest/scalers/metrics-api/
+func (q *QueueClient) ScalerMetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		info, err := q.GetQueueRuntimeInfo(r.Context())
+		if err != nil {
+			q.logError("Fetching queue length for scaler failed", "get_queue", nil, err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ScalerMetrics{
+			QueueName:          q.QueueName,
+			MessageCount:       info.MessageCount,
+			ActiveMessageCount: info.ActiveMessageCount,
+		})
+	})
+}
@@ -0,0 +1,41 @@
+package queue
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_ScalerMetricsHandler(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(respondWith(200, queueEntryXml))
+
+	rec := httptest.NewRecorder()
+	q.ScalerMetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 but got %v", rec.Code)
+	}
+
+	m := ScalerMetrics{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ScalerMetrics{QueueName: "test", MessageCount: 7, ActiveMessageCount: 5}
+	if m != expected {
+		t.Fatalf("Expected %+v but got %+v", expected, m)
+	}
+
+	SetHttpClient(respondWith(200, emptyFeedXml))
+
+	rec = httptest.NewRecorder()
+	q.ScalerMetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 but got %v", rec.Code)
+	}
+}
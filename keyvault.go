@@ -0,0 +1,137 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const keyVaultApiVersion = "7.4"
+
+// Refresh interval of KeyVaultCredentials without a RefreshInterval.
+const DefaultKeyVaultRefreshInterval = time.Hour
+
+// Fetches the SAS key of a QueueClient from an Azure Key Vault secret,
+// so the key doesn't have to live in application config.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/keyvault/secrets/get-secret
+type KeyVaultCredentials struct {
+	// Vault URL e.g. https://myvault.vault.azure.net
+	VaultURL string
+
+	// Name of the secret holding the SAS key, or a connection string providing
+	// both the key and its policy name.
+	SecretName string
+
+	// SAS policy name the key belongs to e.g. RootManageSharedAccessKey.
+	// Not needed if the secret is a connection string.
+	KeyName string
+
+	// Returns an Azure AD access token for the https://vault.azure.net resource,
+	// e.g. one obtained for a managed identity.
+	Token func(ctx context.Context) (string, error)
+
+	// How often Watch fetches the secret again, DefaultKeyVaultRefreshInterval if zero.
+	RefreshInterval time.Duration
+}
+
+// Fetches the key and sets it on the client, typically once at startup.
+func (c *KeyVaultCredentials) Apply(ctx context.Context, q *QueueClient) error {
+
+	secret, err := c.fetch(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !strings.Contains(secret, "SharedAccessKey=") {
+		q.UpdateCredentials(c.KeyName, secret)
+		return nil
+	}
+
+	// the parse error isn't wrapped, as it may quote parts of the secret
+	cs, err := parseConnectionString(secret)
+	if err != nil {
+		return fmt.Errorf("Key Vault secret %s is not a valid connection string", c.SecretName)
+	}
+
+	if q.Namespace != "" && cs.Namespace != q.Namespace {
+		return fmt.Errorf("Key Vault secret %s is for namespace %s, not %s", c.SecretName, cs.Namespace, q.Namespace)
+	}
+
+	q.UpdateCredentials(cs.KeyName, cs.KeyValue)
+	return nil
+}
+
+// Fetches the key every RefreshInterval and sets it on the client, so rotated keys
+// are picked up. Failures are logged and the current key is kept.
+// Blocks until ctx is done.
+func (c *KeyVaultCredentials) Watch(ctx context.Context, q *QueueClient) {
+
+	interval := c.RefreshInterval
+	if interval <= 0 {
+		interval = DefaultKeyVaultRefreshInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Apply(ctx, q); err != nil {
+				logger.Error("Refreshing key from Key Vault failed", err)
+			}
+		}
+	}
+}
+
+func (c *KeyVaultCredentials) fetch(ctx context.Context) (string, error) {
+
+	token, err := c.Token(ctx)
+	if err != nil {
+		return "", wrap(err, "Acquiring Key Vault token failed")
+	}
+
+	url := strings.TrimSuffix(c.VaultURL, "/") + "/secrets/" + c.SecretName + "?api-version=" + keyVaultApiVersion
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", wrap(err, "Request create failed")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := httpClientOverride
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", wrap(err, "Sending GET request failed")
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", wrap(err, "Error reading response body")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Key Vault returned status %v with body %v", resp.StatusCode, string(body))
+	}
+
+	secret := struct {
+		Value string `json:"value"`
+	}{}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", wrap(err, "Error parsing Key Vault secret")
+	}
+
+	return secret.Value, nil
+}
@@ -0,0 +1,108 @@
+package queue
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func Test_KeyVaultCredentials_Apply(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		if req.URL.String() != "https://myvault.vault.azure.net/secrets/sb-key?api-version=7.4" {
+			t.Fatalf("Unexpected URL %v", req.URL)
+		}
+		if req.Header.Get("Authorization") != "Bearer token" {
+			t.Fatalf("Expected bearer token but got %v", req.Header.Get("Authorization"))
+		}
+		return respondWith(200, `{"value":"secret","id":"https://myvault.vault.azure.net/secrets/sb-key/1"}`)(req)
+	}))
+
+	c := &KeyVaultCredentials{
+		VaultURL:   "https://myvault.vault.azure.net/",
+		SecretName: "sb-key",
+		KeyName:    "RootManageSharedAccessKey",
+		Token: func(ctx context.Context) (string, error) {
+			return "token", nil
+		},
+	}
+
+	cli := &QueueClient{}
+	if err := c.Apply(context.Background(), cli); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, value := cli.credentials(); name != "RootManageSharedAccessKey" || value != "secret" {
+		t.Fatalf("Expected credentials to be updated but got %v %v", name, value)
+	}
+}
+
+func Test_KeyVaultCredentials_Apply_error(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(fakeHttpClient(respondWith(403, `{"error":{"code":"Forbidden"}}`)))
+
+	c := &KeyVaultCredentials{
+		VaultURL: "https://myvault.vault.azure.net",
+		Token: func(ctx context.Context) (string, error) {
+			return "token", nil
+		},
+	}
+
+	cli := &QueueClient{KeyName: "key", KeyValue: "value"}
+	if err := c.Apply(context.Background(), cli); err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+
+	if _, value := cli.credentials(); value != "value" {
+		t.Fatalf("Expected credentials to be kept but got %v", value)
+	}
+}
+
+func Test_KeyVaultCredentials_Apply_connectionString(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(fakeHttpClient(respondWith(200,
+		`{"value":"Endpoint=sb://test.servicebus.windows.net/;SharedAccessKeyName=send;SharedAccessKey=secret"}`)))
+
+	c := &KeyVaultCredentials{
+		VaultURL:   "https://myvault.vault.azure.net",
+		SecretName: "sb-connection",
+		Token: func(ctx context.Context) (string, error) {
+			return "token", nil
+		},
+	}
+
+	cli := &QueueClient{Namespace: "test"}
+	if err := c.Apply(context.Background(), cli); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, value := cli.credentials(); name != "send" || value != "secret" {
+		t.Fatalf("Expected credentials of the connection string but got %v %v", name, value)
+	}
+
+	other := &QueueClient{Namespace: "other", KeyName: "key", KeyValue: "value"}
+	if err := c.Apply(context.Background(), other); err == nil {
+		t.Fatalf("Expected error for a connection string of another namespace")
+	}
+
+	if _, value := other.credentials(); value != "value" {
+		t.Fatalf("Expected credentials to be kept but got %v", value)
+	}
+}
+
+func Test_KeyVaultCredentials_Watch_defaultInterval(t *testing.T) {
+
+	c := &KeyVaultCredentials{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// would panic with a zero ticker interval
+	c.Watch(ctx, &QueueClient{})
+}
@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"net/http"
+)
+
+// Closes the client: cancels in-flight receives, stops pending OnLockExpiring callbacks
+// and closes idle connections of the client's own HTTP client.
+// Operations called after Close return ErrClientClosed.
+func (q *QueueClient) Close() error {
+
+	q.mu.Lock()
+	closed := q.done()
+	select {
+	case <-closed:
+		q.mu.Unlock()
+		return nil
+	default:
+		close(closed)
+	}
+	client := q.httpClient
+	q.mu.Unlock()
+
+	q.lockMu.Lock()
+	for token, timer := range q.lockTimers {
+		timer.Stop()
+		delete(q.lockTimers, token)
+	}
+	q.lockMu.Unlock()
+
+	// the package-wide client set by SetHttpClient is shared and left open
+	if c, ok := client.(*http.Client); ok {
+		c.CloseIdleConnections()
+	}
+
+	return nil
+}
+
+// Returns the channel closed by Close. Must be called with q.mu held.
+func (q *QueueClient) done() chan struct{} {
+	if q.closed == nil {
+		q.closed = make(chan struct{})
+	}
+	return q.closed
+}
+
+func (q *QueueClient) checkClosed() error {
+
+	q.mu.Lock()
+	closed := q.done()
+	q.mu.Unlock()
+
+	select {
+	case <-closed:
+		return ErrClientClosed
+	default:
+		return nil
+	}
+}
+
+// Returns a context which is also cancelled when the client is closed.
+func (q *QueueClient) withCancelOnClose(ctx context.Context) (context.Context, context.CancelFunc) {
+
+	q.mu.Lock()
+	closed := q.done()
+	q.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-closed:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
@@ -0,0 +1,70 @@
+package queue
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func Test_Close_cancelsReceive(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test", Timeout: 60}
+
+	started := make(chan struct{})
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		close(started)
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}))
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := cli.GetMessage()
+		result <- err
+	}()
+
+	<-started
+	cli.Close()
+
+	select {
+	case err := <-result:
+		if err == nil {
+			t.Fatal("Expected cancelled receive to fail")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected Close to cancel the in-flight receive")
+	}
+}
+
+func Test_Close(t *testing.T) {
+
+	cli := &QueueClient{
+		Namespace:      "test",
+		QueueName:      "test",
+		OnLockExpiring: func(msg *Message) {},
+	}
+
+	cli.watchLock(&Message{LockToken: "1", LockedUntilUtc: time.Now().Add(time.Hour)})
+
+	if err := cli.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cli.Close(); err != nil {
+		t.Fatalf("Expected repeated Close to succeed but got %v", err)
+	}
+
+	if len(cli.lockTimers) != 0 {
+		t.Fatalf("Expected lock timers to be stopped but got %v", len(cli.lockTimers))
+	}
+
+	if err := cli.SendMessage(NewMessage([]byte("hello"))); err != ErrClientClosed {
+		t.Fatalf("Expected error %v but got %v", ErrClientClosed, err)
+	}
+
+	if _, err := cli.GetMessage(); err != ErrClientClosed {
+		t.Fatalf("Expected error %v but got %v", ErrClientClosed, err)
+	}
+}
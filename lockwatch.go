@@ -0,0 +1,52 @@
+package queue
+
+import "time"
+
+// Default time before a lock expires at which QueueClient.OnLockExpiring is called.
+const DefaultLockExpiringLead = 5 * time.Second
+
+// Schedules OnLockExpiring to be called shortly before the lock of the message expires.
+func (q *QueueClient) watchLock(msg *Message) {
+
+	if q.OnLockExpiring == nil || msg.LockedUntilUtc.IsZero() {
+		return
+	}
+
+	lead := q.LockExpiringLead
+	if lead == 0 {
+		lead = DefaultLockExpiringLead
+	}
+
+	delay := msg.LockRemaining(nil) - lead
+	if delay < 0 {
+		delay = 0
+	}
+
+	q.lockMu.Lock()
+	defer q.lockMu.Unlock()
+
+	if q.lockTimers == nil {
+		q.lockTimers = map[string]*time.Timer{}
+	}
+
+	callback := q.OnLockExpiring
+	q.lockTimers[msg.LockToken] = time.AfterFunc(delay, func() {
+		q.lockMu.Lock()
+		delete(q.lockTimers, msg.LockToken)
+		q.lockMu.Unlock()
+
+		callback(msg)
+	})
+}
+
+// Cancels the pending OnLockExpiring call for a settled message.
+func (q *QueueClient) unwatchLock(msg *Message) {
+
+	q.lockMu.Lock()
+	defer q.lockMu.Unlock()
+
+	if timer, ok := q.lockTimers[msg.LockToken]; ok {
+		timer.Stop()
+		delete(q.lockTimers, msg.LockToken)
+	}
+}
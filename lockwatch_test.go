@@ -0,0 +1,50 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_watchLock(t *testing.T) {
+
+	expiring := make(chan *Message, 1)
+	cli := &QueueClient{
+		OnLockExpiring:   func(msg *Message) { expiring <- msg },
+		LockExpiringLead: time.Minute,
+	}
+
+	msg := &Message{LockToken: "1", LockedUntilUtc: time.Now().Add(time.Minute + 50*time.Millisecond)}
+	cli.watchLock(msg)
+
+	select {
+	case m := <-expiring:
+		if m != msg {
+			t.Fatalf("Expected callback for message %s but got %s", msg.LockToken, m.LockToken)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected OnLockExpiring to be called")
+	}
+
+	if len(cli.lockTimers) != 0 {
+		t.Fatalf("Expected no pending timers but got %v", len(cli.lockTimers))
+	}
+}
+
+func Test_unwatchLock(t *testing.T) {
+
+	expiring := make(chan *Message, 1)
+	cli := &QueueClient{
+		OnLockExpiring:   func(msg *Message) { expiring <- msg },
+		LockExpiringLead: time.Minute,
+	}
+
+	msg := &Message{LockToken: "1", LockedUntilUtc: time.Now().Add(time.Minute + 50*time.Millisecond)}
+	cli.watchLock(msg)
+	cli.unwatchLock(msg)
+
+	select {
+	case <-expiring:
+		t.Fatal("Expected OnLockExpiring not to be called for a settled message")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
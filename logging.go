@@ -1,27 +1,128 @@
 package queue
 
-import "log"
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
 
 type Log func(...interface{})
 
+// Receives a log entry along with its structured context.
+type StructuredLog func(msg string, fields Fields)
+
+// Structured context of a log entry.
+type Fields map[string]interface{}
+
+// Masks sensitive data such as emails, tokens or card numbers before it is logged.
+// Receives the name of a structured field, or an empty name for the entry's text.
+type Scrubber func(field string, value string) string
+
+// Names of the structured log fields.
+const (
+	FieldNamespace     = "namespace"
+	FieldQueue         = "queue"
+	FieldOperation     = "operation"
+	FieldMessageId     = "message_id"
+	FieldDeliveryCount = "delivery_count"
+	FieldStatusCode    = "status_code"
+	FieldError         = "error"
+)
+
+// Renders the fields as space separated key=value pairs sorted by key.
+func (f Fields) String() string {
+
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%v", k, f[k])
+	}
+	return strings.Join(pairs, " ")
+}
+
 type internalLogger struct {
 	logDebug Log
 	logError Log
+
+	structuredDebug StructuredLog
+	structuredError StructuredLog
+
+	scrub Scrubber
 }
 
 func (l internalLogger) Debug(v ...interface{}) {
-	if l.logDebug != nil {
-		l.logDebug(v)
+	if l.structuredDebug != nil {
+		l.structuredDebug(l.scrubText(fmt.Sprint(v...)), Fields{})
+	} else if l.logDebug != nil {
+		l.logDebug(l.scrubValues(v))
 	}
 }
 
 func (l internalLogger) Error(v ...interface{}) {
-	if l.logError != nil {
-		l.logError(v)
+	if l.structuredError != nil {
+		l.structuredError(l.scrubText(fmt.Sprint(v...)), Fields{})
+	} else if l.logError != nil {
+		l.logError(l.scrubValues(v))
+	}
+}
+
+// Logs a debug entry with structured context. Plain loggers receive the fields as key=value pairs.
+func (l internalLogger) DebugFields(msg string, fields Fields) {
+	msg, fields = l.scrubText(msg), l.scrubFields(fields)
+	if l.structuredDebug != nil {
+		l.structuredDebug(msg, fields)
+	} else if l.logDebug != nil {
+		l.logDebug(msg, " ", fields)
+	}
+}
+
+// Logs an error entry with structured context. Plain loggers receive the fields as key=value pairs.
+func (l internalLogger) ErrorFields(msg string, fields Fields) {
+	msg, fields = l.scrubText(msg), l.scrubFields(fields)
+	if l.structuredError != nil {
+		l.structuredError(msg, fields)
+	} else if l.logError != nil {
+		l.logError(msg, " ", fields)
+	}
+}
+
+func (l internalLogger) scrubText(text string) string {
+	if l.scrub == nil {
+		return text
+	}
+	return l.scrub("", text)
+}
+
+// Plain entries are scrubbed as a whole since values like errors may embed sensitive text.
+func (l internalLogger) scrubValues(v []interface{}) interface{} {
+	if l.scrub == nil {
+		return v
+	}
+	return l.scrub("", fmt.Sprint(v...))
+}
+
+func (l internalLogger) scrubFields(fields Fields) Fields {
+	if l.scrub == nil {
+		return fields
+	}
+
+	scrubbed := make(Fields, len(fields))
+	for k, v := range fields {
+		if s, ok := v.(string); ok {
+			v = l.scrub(k, s)
+		}
+		scrubbed[k] = v
 	}
+	return scrubbed
 }
 
-var logger internalLogger = internalLogger{log.Print, log.Print}
+var logger internalLogger = internalLogger{logDebug: log.Print, logError: log.Print}
 
 // Sets the package's debug logger. Pass nil to disable debug logging.
 func SetDebugLogger(log Log) {
@@ -31,4 +132,63 @@ func SetDebugLogger(log Log) {
 // Sets the package's error logger. Pass nil to disable error logging.
 func SetErrorLogger(log Log) {
 	logger.logError = log
-}
\ No newline at end of file
+}
+
+// Sets a debug logger receiving structured fields such as namespace, queue, operation,
+// message id, delivery count and status code. It takes precedence over SetDebugLogger.
+// Pass nil to go back to the plain debug logger.
+func SetStructuredDebugLogger(log StructuredLog) {
+	logger.structuredDebug = log
+}
+
+// Sets an error logger receiving structured fields. It takes precedence over SetErrorLogger.
+// Pass nil to go back to the plain error logger.
+func SetStructuredErrorLogger(log StructuredLog) {
+	logger.structuredError = log
+}
+
+// Sets a function masking entry texts and string fields before they reach any logger.
+// Pass nil to log entries unchanged.
+func SetLogScrubber(scrub Scrubber) {
+	logger.scrub = scrub
+}
+
+// Returns the fields identifying an operation of the client, optionally on a message.
+func (q *QueueClient) logFields(operation string, msg *Message) Fields {
+
+	fields := Fields{
+		FieldNamespace: q.Namespace,
+		FieldQueue:     q.QueueName,
+		FieldOperation: operation,
+	}
+
+	if msg != nil {
+		fields[FieldMessageId] = msg.Id
+		fields[FieldDeliveryCount] = msg.DeliveryCount
+	}
+
+	return fields
+}
+
+// Logs the outcome of a request made by the operation.
+func (q *QueueClient) logResponse(operation string, msg *Message, statusCode int, err error) {
+
+	fields := q.logFields(operation, msg)
+	fields[FieldStatusCode] = statusCode
+
+	if err != nil {
+		fields[FieldError] = err.Error()
+		logger.DebugFields("Request failed", fields)
+		return
+	}
+
+	logger.DebugFields("Request succeeded", fields)
+}
+
+// Logs a failure of the operation which can't be returned to the caller.
+func (q *QueueClient) logError(text string, operation string, msg *Message, err error) {
+
+	fields := q.logFields(operation, msg)
+	fields[FieldError] = err.Error()
+	logger.ErrorFields(text, fields)
+}
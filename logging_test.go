@@ -1,6 +1,10 @@
 package queue
 
-import "testing"
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
 
 func Test_internalLogger(t *testing.T) {
 
@@ -44,4 +48,80 @@ func Test_internalLogger(t *testing.T) {
 	if errorOutput != false {
 		t.Fatalf("Expected custom error function to be reset")
 	}
-}
\ No newline at end of file
+}
+func Test_structuredLogger(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	defer SetStructuredDebugLogger(nil)
+
+	var entries []Fields
+	SetStructuredDebugLogger(func(msg string, fields Fields) {
+		entries = append(entries, fields)
+	})
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		resp, _ := respondWith(201, "hello")(req)
+		resp.Header.Set("BrokerProperties", `{"MessageId":"abc","DeliveryCount":2}`)
+		return resp, nil
+	}))
+
+	if _, err := q.GetMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) == 0 {
+		t.Fatalf("Expected structured log entries")
+	}
+
+	fields := entries[len(entries)-1]
+	expected := Fields{
+		FieldNamespace:     "test",
+		FieldQueue:         "test",
+		FieldOperation:     "receive",
+		FieldMessageId:     "abc",
+		FieldDeliveryCount: 2,
+		FieldStatusCode:    201,
+	}
+
+	if fields.String() != expected.String() {
+		t.Fatalf("Expected fields %v but got %v", expected, fields)
+	}
+}
+
+func Test_Fields_String(t *testing.T) {
+
+	fields := Fields{FieldQueue: "orders", FieldStatusCode: 404, FieldError: "not found"}
+	expected := "error=not found queue=orders status_code=404"
+
+	if fields.String() != expected {
+		t.Fatalf("Expected %q but got %q", expected, fields.String())
+	}
+}
+
+func Test_logScrubber(t *testing.T) {
+
+	defer SetStructuredDebugLogger(nil)
+	defer SetLogScrubber(nil)
+
+	var texts []string
+	var entries []Fields
+	SetStructuredDebugLogger(func(msg string, fields Fields) {
+		texts = append(texts, msg)
+		entries = append(entries, fields)
+	})
+
+	SetLogScrubber(func(field string, value string) string {
+		return strings.Replace(value, "jane@example.com", "***", -1)
+	})
+
+	logger.Debug("Response BrokerProperties ", `{"ReplyTo":"jane@example.com"}`)
+	logger.DebugFields("Request failed", Fields{FieldError: "unknown user jane@example.com", FieldStatusCode: 400})
+
+	if texts[0] != `Response BrokerProperties {"ReplyTo":"***"}` {
+		t.Fatalf("Expected scrubbed text but got %v", texts[0])
+	}
+
+	if entries[1][FieldError] != "unknown user ***" || entries[1][FieldStatusCode] != 400 {
+		t.Fatalf("Expected scrubbed fields but got %v", entries[1])
+	}
+}
@@ -0,0 +1,193 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const managementApiVersion = "2017-04"
+
+const azureEntityURL = "https://%s.servicebus.windows.net:443/%s"
+
+const contentTypeAtomEntry = "application/atom+xml;type=entry;charset=utf-8"
+
+// Message counts and size of a queue.
+type QueueRuntimeInfo struct {
+	// Total number of messages in the queue.
+	MessageCount int64
+
+	ActiveMessageCount             int64
+	DeadLetterMessageCount         int64
+	ScheduledMessageCount          int64
+	TransferMessageCount           int64
+	TransferDeadLetterMessageCount int64
+
+	// Size of the queue in bytes.
+	SizeInBytes int64
+}
+
+// Retrieves the properties of the queue.
+// Returns QueueDontExistError if the queue doesn't exist.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/get-entity
+func (q *QueueClient) GetQueue(ctx context.Context) (*QueueDescription, error) {
+
+	body, err := q.manage(ctx, "get_queue", "GET", q.QueueName, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := queueEntry{}
+	if err := parseEntry(body, &entry); err != nil {
+		return nil, err
+	}
+
+	desc, err := entry.Content.Description.toDescription()
+	if err != nil {
+		return nil, err
+	}
+
+	return &desc, nil
+}
+
+// Reports whether the queue exists, e.g. to fail fast at startup
+// rather than on the first receive.
+func (q *QueueClient) QueueExists(ctx context.Context) (bool, error) {
+
+	_, err := q.GetQueue(ctx)
+	if _, missing := err.(QueueDontExistError); missing {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Retrieves the current message counts of the queue.
+// Returns QueueDontExistError if the queue doesn't exist.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/get-entity
+func (q *QueueClient) GetQueueRuntimeInfo(ctx context.Context) (*QueueRuntimeInfo, error) {
+
+	body, err := q.manage(ctx, "get_queue", "GET", q.QueueName, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := queueEntry{}
+	if err := parseEntry(body, &entry); err != nil {
+		return nil, err
+	}
+
+	d := entry.Content.Description
+	info := &QueueRuntimeInfo{
+		MessageCount: d.MessageCount,
+		SizeInBytes:  d.SizeInBytes,
+	}
+
+	if c := d.CountDetails; c != nil {
+		info.ActiveMessageCount = c.ActiveMessageCount
+		info.DeadLetterMessageCount = c.DeadLetterMessageCount
+		info.ScheduledMessageCount = c.ScheduledMessageCount
+		info.TransferMessageCount = c.TransferMessageCount
+		info.TransferDeadLetterMessageCount = c.TransferDeadLetterMessageCount
+	}
+
+	return info, nil
+}
+
+// Sends a request made by the operation to the management endpoint of the entity
+// at entityPath, e.g. the queue name, and returns the response body.
+func (q *QueueClient) manage(ctx context.Context, operation string, method string, entityPath string, header http.Header, body []byte) ([]byte, error) {
+
+	if err := q.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	if err := q.checkFIPS(); err != nil {
+		return nil, err
+	}
+
+	resource := fmt.Sprintf(azureEntityURL, q.Namespace, entityPath)
+	url := resource
+	if q.GatewayURL != "" {
+		url = strings.TrimSuffix(q.GatewayURL, "/") + "/" + entityPath
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, url+"?api-version="+managementApiVersion, reader)
+	if err != nil {
+		return nil, wrap(err, "Request create failed")
+	}
+
+	for k, v := range q.Headers {
+		req.Header.Set(k, v)
+	}
+
+	for k := range header {
+		req.Header.Set(k, header.Get(k))
+	}
+
+	if body != nil {
+		req.Header.Set(headerContentType, contentTypeAtomEntry)
+	}
+
+	req.Header.Set("Authorization", q.makeAuthHeader(resource, time.Now()))
+
+	resp, err := q.do(operation, q.getClient(), req.WithContext(ctx))
+	if err != nil {
+		q.countError(operation, err)
+		return nil, wrap(err, "Sending "+method+" request failed")
+	}
+
+	defer resp.Body.Close()
+
+	err = handleStatusCode(resp)
+	q.logResponse(operation, nil, resp.StatusCode, err)
+
+	if err != nil {
+		q.countError(operation, err)
+		return nil, err
+	}
+
+	respBody, err := readBody(resp.Body, q.MaxResponseBodySize)
+	if _, ok := err.(ResponseTooLargeError); ok {
+		return nil, err
+	}
+	if err != nil {
+		return nil, wrap(err, "Error reading response body")
+	}
+
+	return respBody, nil
+}
+
+// Parses an Atom entry describing an entity. The service responds to requests
+// for entities which don't exist with an empty feed rather than 404.
+func parseEntry(body []byte, entry interface{}) error {
+
+	root := struct {
+		XMLName xml.Name
+	}{}
+	if err := xml.Unmarshal(body, &root); err != nil {
+		return wrap(err, "Error parsing entity description")
+	}
+
+	if root.XMLName.Local != "entry" {
+		return QueueDontExistError{Code: http.StatusNotFound, Body: string(body)}
+	}
+
+	if err := xml.Unmarshal(body, entry); err != nil {
+		return wrap(err, "Error parsing entity description")
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const queueEntryXml = `<entry xmlns="http://www.w3.org/2005/Atom">
+  <id>https://test.servicebus.windows.net/test?api-version=2017-04</id>
+  <title type="text">test</title>
+  <content type="application/xml">
+    <QueueDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect" xmlns:i="http://www.w3.org/2001/XMLSchema-instance">
+      <LockDuration>PT1M</LockDuration>
+      <MaxSizeInMegabytes>1024</MaxSizeInMegabytes>
+      <RequiresDuplicateDetection>false</RequiresDuplicateDetection>
+      <RequiresSession>false</RequiresSession>
+      <DefaultMessageTimeToLive>P14D</DefaultMessageTimeToLive>
+      <DeadLetteringOnMessageExpiration>false</DeadLetteringOnMessageExpiration>
+      <DuplicateDetectionHistoryTimeWindow>PT10M</DuplicateDetectionHistoryTimeWindow>
+      <MaxDeliveryCount>10</MaxDeliveryCount>
+      <EnableBatchedOperations>true</EnableBatchedOperations>
+      <SizeInBytes>2048</SizeInBytes>
+      <MessageCount>7</MessageCount>
+      <Status>Active</Status>
+      <CreatedAt>2018-01-01T01:01:01.5Z</CreatedAt>
+      <CountDetails xmlns:d2p1="http://schemas.microsoft.com/netservices/2011/06/servicebus">
+        <d2p1:ActiveMessageCount>5</d2p1:ActiveMessageCount>
+        <d2p1:DeadLetterMessageCount>1</d2p1:DeadLetterMessageCount>
+        <d2p1:ScheduledMessageCount>1</d2p1:ScheduledMessageCount>
+        <d2p1:TransferMessageCount>0</d2p1:TransferMessageCount>
+        <d2p1:TransferDeadLetterMessageCount>0</d2p1:TransferDeadLetterMessageCount>
+      </CountDetails>
+      <AutoDeleteOnIdle>P10675199DT2H48M5.4775807S</AutoDeleteOnIdle>
+      <EnablePartitioning>false</EnablePartitioning>
+    </QueueDescription>
+  </content>
+</entry>`
+
+const emptyFeedXml = `<feed xmlns="http://www.w3.org/2005/Atom"><title type="text">Publicly Listed Services</title></feed>`
+
+func Test_GetQueueRuntimeInfo(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method != "GET" || req.URL.String() != "https://test.servicebus.windows.net:443/test?api-version=2017-04" {
+			t.Fatalf("Unexpected request %v %v", req.Method, req.URL)
+		}
+		return respondWith(200, queueEntryXml)(req)
+	}))
+
+	info, err := q.GetQueueRuntimeInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := QueueRuntimeInfo{
+		MessageCount:           7,
+		ActiveMessageCount:     5,
+		DeadLetterMessageCount: 1,
+		ScheduledMessageCount:  1,
+		SizeInBytes:            2048,
+	}
+
+	if *info != expected {
+		t.Fatalf("Expected %+v but got %+v", expected, *info)
+	}
+}
+
+func Test_GetQueueRuntimeInfo_missingQueue(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(respondWith(200, emptyFeedXml))
+
+	_, err := q.GetQueueRuntimeInfo(context.Background())
+	if _, ok := err.(QueueDontExistError); !ok {
+		t.Fatalf("Expected error type QueueDontExistError but got %v", err)
+	}
+}
+
+func Test_GetQueue(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(respondWith(200, queueEntryXml))
+
+	desc, err := q.GetQueue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := QueueDescription{
+		LockDuration:                        time.Minute,
+		MaxSizeInMegabytes:                  1024,
+		DefaultMessageTimeToLive:            14 * 24 * time.Hour,
+		MaxDeliveryCount:                    10,
+		DuplicateDetectionHistoryTimeWindow: 10 * time.Minute,
+		AutoDeleteOnIdle:                    math.MaxInt64,
+		EnableBatchedOperations:             true,
+		Status:                              "Active",
+		SizeInBytes:                         2048,
+		MessageCount:                        7,
+		CreatedAt:                           time.Date(2018, 1, 1, 1, 1, 1, 500000000, time.UTC),
+	}
+
+	if *desc != expected {
+		t.Fatalf("Expected %+v but got %+v", expected, *desc)
+	}
+}
+
+func Test_QueueExists(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	tests := []struct {
+		code     int
+		body     string
+		expected bool
+		fails    bool
+	}{
+		{200, queueEntryXml, true, false},
+		{200, emptyFeedXml, false, false},
+		{401, "", false, true},
+	}
+
+	for _, test := range tests {
+		SetHttpClient(respondWith(test.code, test.body))
+
+		exists, err := q.QueueExists(context.Background())
+		if (err != nil) != test.fails {
+			t.Fatalf("Expected failure %v but got %v", test.fails, err)
+		}
+		if exists != test.expected {
+			t.Fatalf("Expected exists %v but got %v", test.expected, exists)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"expvar"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	counterSend     = "send"
+	counterReceive  = "receive"
+	counterComplete = "complete"
+	counterAbandon  = "abandon"
+	counterError    = "error"
+)
+
+// Names of the tagged counters.
+const (
+	// Requests made to Service Bus, tagged with operation, queue and status class.
+	MetricRequests = "requests"
+
+	// Messages sent, received, completed or abandoned, tagged with operation and queue.
+	MetricMessages = "messages"
+
+	// Failed operations, tagged with operation and queue.
+	MetricErrors = "errors"
+)
+
+// Names of the metric tags.
+const (
+	TagOperation   = "operation"
+	TagQueue       = "queue"
+	TagStatusClass = "status_class"
+)
+
+// Tags of a metric, e.g. operation, queue name and status code class.
+type Tags map[string]string
+
+// Renders the tags as comma separated key=value pairs sorted by key.
+func (t Tags) String() string {
+
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + t[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Receives the package's tagged metrics, e.g. to forward them to a monitoring system.
+type MetricsSink interface {
+	// Adds delta to the counter with the given name and tags.
+	Count(name string, delta int64, tags Tags)
+}
+
+// Package counters. They are always collected but only exposed
+// on /debug/vars once PublishExpvar is called.
+var stats = new(expvar.Map).Init()
+
+var publishOnce sync.Once
+
+var metricsSink MetricsSink = expvarSink{}
+
+// Publishes the package's send/receive/complete/abandon/error counters
+// via expvar under the "azurequeue" name. Safe to call more than once.
+//
+// These untagged counters are totals over all clients. The same counts are reported
+// to the metrics sink per queue as MetricMessages and MetricErrors. Unless another
+// sink is set with SetMetricsSink, the tagged counters are published alongside them
+// with keys like requests{operation=send,queue=orders,status_class=2xx}.
+func PublishExpvar() {
+	publishOnce.Do(func() {
+		expvar.Publish("azurequeue", stats)
+	})
+}
+
+// Sets the sink receiving the package's tagged metrics. Pass nil to restore the expvar sink.
+func SetMetricsSink(sink MetricsSink) {
+	if sink == nil {
+		sink = expvarSink{}
+	}
+	metricsSink = sink
+}
+
+type expvarSink struct{}
+
+func (expvarSink) Count(name string, delta int64, tags Tags) {
+	stats.Add(name+"{"+tags.String()+"}", delta)
+}
+
+// Counts a failed operation. An empty queue is not considered a failure.
+func (q *QueueClient) countError(operation string, err error) {
+	if _, ok := err.(NoMessagesAvailableError); ok {
+		return
+	}
+	stats.Add(counterError, 1)
+	metricsSink.Count(MetricErrors, 1, Tags{TagOperation: operation, TagQueue: q.QueueName})
+}
+
+// Counts messages which were sent, received, completed or abandoned.
+// The counter name doubles as the operation tag.
+func (q *QueueClient) countMessages(counter string, delta int64) {
+	stats.Add(counter, delta)
+	metricsSink.Count(MetricMessages, delta, Tags{TagOperation: counter, TagQueue: q.QueueName})
+}
+
+// Counts a request made by the operation. A zero status code stands for
+// a request which failed without a response.
+func (q *QueueClient) countRequest(operation string, statusCode int) {
+	metricsSink.Count(MetricRequests, 1, Tags{
+		TagOperation:   operation,
+		TagQueue:       q.QueueName,
+		TagStatusClass: statusClass(statusCode),
+	})
+}
+
+// Returns the class of the status code, e.g. 4xx, or "error" if there's none.
+func statusClass(statusCode int) string {
+	if statusCode == 0 {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", statusCode/100)
+}
@@ -0,0 +1,119 @@
+package queue
+
+import (
+	"context"
+	"expvar"
+	"testing"
+)
+
+func counterValue(name string) int64 {
+	if v, ok := stats.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func Test_counters(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	locked := testMsg
+	locked.LockToken = "lock"
+
+	tests := []struct {
+		code    int
+		call    func() error
+		counter string
+	}{
+		{201, func() error { return q.SendMessage(NewMessage([]byte("hello"))) }, counterSend},
+		{200, func() error { _, err := q.GetMessage(); return err }, counterReceive},
+		{200, func() error { return q.DeleteMessage(&locked) }, counterComplete},
+		{200, func() error { return q.UnlockMessage(&locked) }, counterAbandon},
+		{500, func() error { return q.SendMessage(NewMessage([]byte("hello"))) }, counterError},
+	}
+
+	for _, test := range tests {
+		SetHttpClient(respondWith(test.code, ""))
+
+		before := counterValue(test.counter)
+		test.call()
+
+		if after := counterValue(test.counter); after != before+1 {
+			t.Fatalf("Expected counter %s to be %v but got %v", test.counter, before+1, after)
+		}
+	}
+}
+
+func Test_counters_noMessages(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	SetHttpClient(respondWith(204, ""))
+
+	before := counterValue(counterError)
+	q.GetMessage()
+
+	if after := counterValue(counterError); after != before {
+		t.Fatalf("Expected empty queue not to be counted as error but got %v errors", after-before)
+	}
+}
+
+func Test_PublishExpvar(t *testing.T) {
+
+	PublishExpvar()
+	PublishExpvar()
+
+	if expvar.Get("azurequeue") != stats {
+		t.Fatal("Expected counters to be published as azurequeue")
+	}
+}
+
+type recordingSink struct {
+	counts map[string]int64
+}
+
+func (s *recordingSink) Count(name string, delta int64, tags Tags) {
+	s.counts[name+"{"+tags.String()+"}"] += delta
+}
+
+func Test_taggedMetrics(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	defer SetMetricsSink(nil)
+
+	sink := &recordingSink{counts: map[string]int64{}}
+	SetMetricsSink(sink)
+
+	SetHttpClient(respondWith(201, ""))
+	q.SendMessage(NewMessage([]byte("hello")))
+
+	SetHttpClient(respondWith(404, ""))
+	q.DeleteMessage(&Message{Id: "abc", LockToken: "lock"})
+
+	SetHttpClient(respondWith(200, ""))
+	q.WarmUp(context.Background())
+
+	expected := map[string]int64{
+		"requests{operation=send,queue=test,status_class=2xx}":     1,
+		"requests{operation=complete,queue=test,status_class=4xx}": 1,
+		"requests{operation=warm_up,queue=test,status_class=2xx}":  1,
+		"messages{operation=send,queue=test}":                      1,
+		"errors{operation=complete,queue=test}":                    1,
+	}
+
+	for key, count := range expected {
+		if sink.counts[key] != count {
+			t.Fatalf("Expected %s to be %v but got %v", key, count, sink.counts)
+		}
+	}
+}
+
+func Test_statusClass(t *testing.T) {
+
+	cases := map[int]string{0: "error", 201: "2xx", 404: "4xx", 503: "5xx"}
+
+	for code, expected := range cases {
+		if class := statusClass(code); class != expected {
+			t.Fatalf("Expected class %v for %v but got %v", expected, code, class)
+		}
+	}
+}
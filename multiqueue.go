@@ -0,0 +1,194 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Server-side wait in seconds of a MultiQueueReceiver without a Timeout.
+const DefaultMultiQueueTimeout = 30
+
+// Longest lock duration Service Bus allows, how long messages without
+// LockedUntilUtc are tracked by a MultiQueueReceiver.
+const maxLockDuration = 5 * time.Minute
+
+// Receives from several queues in priority order, emulating priority queues on Service Bus,
+// e.g. orders-high and orders-low. A message is always taken from the highest priority
+// queue which has one.
+//
+// Thread-safe. Received messages have to be settled through the MultiQueueReceiver.
+type MultiQueueReceiver struct {
+	// Queues to receive from, highest priority first.
+	Queues []*QueueClient
+
+	// Server-side wait in seconds for a message when all queues are empty,
+	// DefaultMultiQueueTimeout if zero.
+	Timeout int
+
+	mu      sync.Mutex
+	sources map[string]source
+}
+
+// Queue a message was received from, tracked until the message's lock expires.
+type source struct {
+	queue       *QueueClient
+	lockedUntil time.Time
+}
+
+var _ Receiver = (*MultiQueueReceiver)(nil)
+
+// Retrieves and locks the next message from the highest priority non-empty queue.
+// Queues are checked without waiting on the server first. If all of them are empty,
+// they are long-polled at once for up to Timeout seconds and the first message
+// received is returned, or NoMessagesAvailableError if none arrived.
+func (r *MultiQueueReceiver) GetMessage() (*Message, error) {
+
+	for _, q := range r.Queues {
+
+		msg, err := q.receive(context.Background(), 0)
+
+		if _, ok := err.(NoMessagesAvailableError); ok {
+			continue
+		}
+
+		r.track(q, receiveResult{msg, err})
+
+		if err != nil {
+			return nil, wrap(err, "Receiving from "+q.QueueName+" failed")
+		}
+
+		return msg, nil
+	}
+
+	return r.wait()
+}
+
+// Long-polls all queues at once and returns the first message received.
+// Messages received by the other polls before they are cancelled are unlocked.
+func (r *MultiQueueReceiver) wait() (*Message, error) {
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultMultiQueueTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type result struct {
+		queue *QueueClient
+		receiveResult
+	}
+
+	results := make(chan result, len(r.Queues))
+	for _, q := range r.Queues {
+		go func(q *QueueClient) {
+			msg, err := q.receive(ctx, timeout)
+			results <- result{q, receiveResult{msg, err}}
+		}(q)
+	}
+
+	var winner *result
+	var failure error
+
+	for range r.Queues {
+		res := <-results
+
+		if _, ok := res.err.(NoMessagesAvailableError); ok || ctx.Err() != nil && res.locked() == nil {
+			continue
+		}
+
+		if winner != nil {
+			if msg := res.locked(); msg != nil {
+				if err := res.queue.UnlockMessage(msg); err != nil {
+					res.queue.logError("Unlocking message failed", "abandon", msg, err)
+				}
+			}
+			continue
+		}
+
+		if res.locked() == nil {
+			failure = wrap(res.err, "Receiving from "+res.queue.QueueName+" failed")
+			continue
+		}
+
+		winner = &res
+		r.track(res.queue, res.receiveResult)
+		cancel()
+	}
+
+	switch {
+	case winner == nil && failure != nil:
+		return nil, failure
+	case winner == nil:
+		return nil, NoMessagesAvailableError{Code: 204}
+	case winner.err != nil:
+		return nil, wrap(winner.err, "Receiving from "+winner.queue.QueueName+" failed")
+	}
+
+	return winner.msg, nil
+}
+
+// Remembers the queue a received message was locked on, also if it came with an error,
+// so it can be settled. Entries of messages whose lock expired are dropped.
+func (r *MultiQueueReceiver) track(q *QueueClient, res receiveResult) {
+
+	msg := res.locked()
+	if msg == nil {
+		return
+	}
+
+	now := clock.Now()
+
+	lockedUntil := msg.LockedUntilUtc
+	if lockedUntil.IsZero() {
+		lockedUntil = now.Add(maxLockDuration)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.sources == nil {
+		r.sources = map[string]source{}
+	}
+
+	for token, s := range r.sources {
+		if !now.Before(s.lockedUntil) {
+			delete(r.sources, token)
+		}
+	}
+
+	r.sources[msg.LockToken] = source{q, lockedUntil}
+}
+
+// Unlocks a message on the queue it was received from.
+func (r *MultiQueueReceiver) UnlockMessage(msg *Message) error {
+	return r.settle(msg, (*QueueClient).UnlockMessage)
+}
+
+// Deletes a message from the queue it was received from.
+func (r *MultiQueueReceiver) DeleteMessage(msg *Message) error {
+	return r.settle(msg, (*QueueClient).DeleteMessage)
+}
+
+func (r *MultiQueueReceiver) settle(msg *Message, op func(*QueueClient, *Message) error) error {
+
+	r.mu.Lock()
+	s, ok := r.sources[msg.LockToken]
+	r.mu.Unlock()
+
+	if !ok {
+		return MessageDontExistError{Code: 404, Body: "Message was not received by this receiver or its lock expired"}
+	}
+
+	if err := op(s.queue, msg); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	delete(r.sources, msg.LockToken)
+	r.mu.Unlock()
+
+	return nil
+}
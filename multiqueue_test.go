@@ -0,0 +1,151 @@
+package queue
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_MultiQueueReceiver(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	// queue name -> number of messages available
+	available := map[string]int{"high": 1, "low": 2}
+	var deleted []string
+	var waits []string
+
+	var mu sync.Mutex
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		name := strings.Split(req.URL.Path, "/")[1]
+
+		if req.Method == "DELETE" {
+			deleted = append(deleted, name)
+			return respondWith(200, "")(req)
+		}
+
+		if timeout := req.URL.Query().Get("timeout"); timeout != "0" {
+			waits = append(waits, name+" "+timeout)
+		}
+
+		if available[name] == 0 {
+			return respondWith(204, "")(req)
+		}
+		available[name]--
+
+		resp, _ := respondWith(200, name)(req)
+		resp.Header.Set(headerBrokerProperties, `{"MessageId":"id","LockToken":"`+name+strconv.Itoa(available[name])+`"}`)
+		return resp, nil
+	}))
+
+	r := &MultiQueueReceiver{Queues: []*QueueClient{
+		{Namespace: "test", QueueName: "high"},
+		{Namespace: "test", QueueName: "low"},
+	}}
+
+	for _, expected := range []string{"high", "low", "low"} {
+		msg, err := r.GetMessage()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(msg.Body) != expected {
+			t.Fatalf("Expected message from %s but got %s", expected, string(msg.Body))
+		}
+
+		if err := r.DeleteMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+
+		if deleted[len(deleted)-1] != expected {
+			t.Fatalf("Expected message to be deleted from %s but got %s", expected, deleted[len(deleted)-1])
+		}
+	}
+
+	if len(waits) != 0 {
+		t.Fatalf("Expected queues with messages to be polled without waiting but got %v", waits)
+	}
+
+	if _, err := r.GetMessage(); err == nil {
+		t.Fatal("Expected NoMessagesAvailableError")
+	} else if _, ok := err.(NoMessagesAvailableError); !ok {
+		t.Fatalf("Expected error type NoMessagesAvailableError but got %v", err)
+	}
+
+	sort.Strings(waits)
+	if strings.Join(waits, ",") != "high 30,low 30" {
+		t.Fatalf("Expected empty queues to be long-polled but got %v", waits)
+	}
+
+	if err := r.UnlockMessage(&Message{LockToken: "unknown"}); err == nil {
+		t.Fatal("Expected error for message not received by the receiver")
+	}
+}
+
+func Test_MultiQueueReceiver_wait(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		name := strings.Split(req.URL.Path, "/")[1]
+
+		switch {
+		case req.Method == "DELETE":
+			return respondWith(200, "")(req)
+		case req.URL.Query().Get("timeout") == "0":
+			return respondWith(204, "")(req)
+		case name == "high":
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+
+		resp, _ := respondWith(200, name)(req)
+		resp.Header.Set(headerBrokerProperties, `{"MessageId":"id","LockToken":"lock"}`)
+		return resp, nil
+	}))
+
+	r := &MultiQueueReceiver{Queues: []*QueueClient{
+		{Namespace: "test", QueueName: "high"},
+		{Namespace: "test", QueueName: "low"},
+	}}
+
+	msg, err := r.GetMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(msg.Body) != "low" {
+		t.Fatalf("Expected message from low but got %s", msg.Body)
+	}
+
+	if err := r.DeleteMessage(msg); err != nil {
+		t.Fatalf("Expected message received while waiting to be settled but got %v", err)
+	}
+}
+
+func Test_MultiQueueReceiver_expiredLocks(t *testing.T) {
+
+	defer SetClock(nil)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	SetClock(fixedClock(now))
+
+	q := &QueueClient{Namespace: "test", QueueName: "high"}
+	r := &MultiQueueReceiver{Queues: []*QueueClient{q}}
+
+	r.track(q, receiveResult{&Message{LockToken: "expired", LockedUntilUtc: now}, nil})
+	r.track(q, receiveResult{&Message{LockToken: "held", LockedUntilUtc: now.Add(time.Minute)}, nil})
+	r.track(q, receiveResult{&Message{LockToken: "unknown"}, nil})
+
+	if _, ok := r.sources["expired"]; ok || len(r.sources) != 2 {
+		t.Fatalf("Expected only the expired lock to be dropped but got %v", r.sources)
+	}
+}
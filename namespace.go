@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"encoding/xml"
+	"time"
+)
+
+// Messaging tiers of a namespace.
+const (
+	SkuBasic    = "Basic"
+	SkuStandard = "Standard"
+	SkuPremium  = "Premium"
+)
+
+// Properties of a Service Bus namespace.
+type NamespaceInfo struct {
+	Name string
+
+	// Messaging tier, one of SkuBasic, SkuStandard and SkuPremium.
+	SKU string
+
+	// Number of messaging units of a Premium namespace.
+	MessagingUnits int
+
+	CreatedAt  time.Time
+	ModifiedAt time.Time
+}
+
+// Returns the maximal size of a message the namespace accepts by default.
+func (n NamespaceInfo) MaxMessageSizeInBytes() int {
+	if n.SKU == SkuPremium {
+		return 1024 * 1024
+	}
+	return 256 * 1024
+}
+
+// Retrieves the properties of the client's namespace, e.g. to adapt to its tier.
+func (q *QueueClient) GetNamespaceInfo(ctx context.Context) (*NamespaceInfo, error) {
+
+	body, err := q.manage(ctx, "get_namespace", "GET", "$namespaceinfo", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := namespaceEntry{}
+	if err := parseEntry(body, &entry); err != nil {
+		return nil, err
+	}
+
+	n := entry.Content.Info
+	info := &NamespaceInfo{
+		Name:           n.Name,
+		SKU:            n.MessagingSKU,
+		MessagingUnits: n.MessagingUnits,
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, n.CreatedTime); err == nil {
+		info.CreatedAt = t
+	}
+	if t, err := time.Parse(time.RFC3339Nano, n.ModifiedTime); err == nil {
+		info.ModifiedAt = t
+	}
+
+	return info, nil
+}
+
+type namespaceEntry struct {
+	XMLName xml.Name `xml:"entry"`
+	Content struct {
+		Info struct {
+			CreatedTime    string `xml:"CreatedTime"`
+			MessagingSKU   string `xml:"MessagingSKU"`
+			MessagingUnits int    `xml:"MessagingUnits"`
+			ModifiedTime   string `xml:"ModifiedTime"`
+			Name           string `xml:"Name"`
+		} `xml:"NamespaceInfo"`
+	} `xml:"content"`
+}
@@ -0,0 +1,55 @@
+package queue
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const namespaceEntryXml = `<entry xmlns="http://www.w3.org/2005/Atom">
+  <title type="text">test</title>
+  <content type="application/xml">
+    <NamespaceInfo xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect" xmlns:i="http://www.w3.org/2001/XMLSchema-instance">
+      <CreatedTime>2018-04-18T06:43:33.17Z</CreatedTime>
+      <MessagingSKU>Premium</MessagingSKU>
+      <MessagingUnits>2</MessagingUnits>
+      <ModifiedTime>2018-05-01T10:00:00Z</ModifiedTime>
+      <Name>test</Name>
+      <NamespaceType>Messaging</NamespaceType>
+    </NamespaceInfo>
+  </content>
+</entry>`
+
+func Test_GetNamespaceInfo(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/$namespaceinfo" {
+			t.Fatalf("Unexpected path %v", req.URL.Path)
+		}
+		return respondWith(200, namespaceEntryXml)(req)
+	}))
+
+	info, err := q.GetNamespaceInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := NamespaceInfo{
+		Name:           "test",
+		SKU:            SkuPremium,
+		MessagingUnits: 2,
+		CreatedAt:      time.Date(2018, 4, 18, 6, 43, 33, 170000000, time.UTC),
+		ModifiedAt:     time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	if *info != expected {
+		t.Fatalf("Expected %+v but got %+v", expected, *info)
+	}
+
+	if info.MaxMessageSizeInBytes() != 1024*1024 {
+		t.Fatalf("Expected 1MB message size limit but got %v", info.MaxMessageSizeInBytes())
+	}
+}
@@ -0,0 +1,25 @@
+package queue
+
+// Stands in for an error which may quote a message body while NeverLogBody is set.
+// The original error is still available through errors.Unwrap.
+type withheldError struct {
+	err error
+}
+
+func (e withheldError) Error() string {
+	return "details withheld as they may contain the message body"
+}
+
+func (e withheldError) Unwrap() error {
+	return e.err
+}
+
+// Hides the text of an error returned by a callback which was given a message body.
+func (q *QueueClient) withholdBody(err error) error {
+
+	if err == nil || !q.NeverLogBody {
+		return err
+	}
+
+	return withheldError{err}
+}
@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func Test_NeverLogBody(t *testing.T) {
+
+	const secret = "4111-1111-1111-1111"
+
+	defer SetHttpClient(nil)
+	defer SetDebugLogger(nil)
+	defer SetErrorLogger(nil)
+
+	var output []string
+	capture := func(v ...interface{}) {
+		output = append(output, fmt.Sprint(v...))
+	}
+	SetDebugLogger(capture)
+	SetErrorLogger(capture)
+
+	status := 201
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		resp, _ := respondWith(status, `{"card":"`+secret+`"}`)(req)
+		resp.Header.Set("BrokerProperties", `{"MessageId":"abc","LockToken":"token"}`)
+		resp.Header.Set("Schema-Id", "card-v1")
+		return resp, nil
+	}))
+
+	echo := testSchemaRegistry{"card-v1": func(body []byte) error {
+		return errors.New("invalid card in " + string(body))
+	}}
+
+	cli := QueueClient{Namespace: "test", KeyName: "key", KeyValue: "keyvalue", QueueName: "test", NeverLogBody: true}
+
+	msg := NewMessage([]byte(`{"card":"` + secret + `"}`))
+	var errs []error
+
+	errs = append(errs, cli.SendMessage(msg), cli.SendBatch([]*Message{msg}))
+	_, err := cli.GetMessage()
+	errs = append(errs, err)
+
+	cli.SchemaRegistry = echo
+	msg.SetSchemaId("card-v1")
+	errs = append(errs, cli.SendMessage(msg), cli.SendBatch([]*Message{msg}))
+	_, err = cli.GetMessage()
+	errs = append(errs, err)
+
+	status = 500
+	errs = append(errs, cli.SendMessage(NewMessage([]byte(secret))))
+
+	for _, err := range errs {
+		if err != nil && strings.Contains(err.Error(), secret) {
+			t.Fatalf("Expected error without message body but got %v", err)
+		}
+		output = append(output, fmt.Sprintf("%+v", err))
+	}
+
+	if len(output) == 0 {
+		t.Fatalf("Expected log output")
+	}
+
+	for _, line := range output {
+		if strings.Contains(line, secret) {
+			t.Fatalf("Expected output without message body but got %v", line)
+		}
+	}
+
+	var schemaErr SchemaError
+	if !errors.As(errs[3], &schemaErr) || errors.Unwrap(schemaErr.Err) == nil {
+		t.Fatalf("Expected SchemaError wrapping the registry error but got %v", errs[3])
+	}
+}
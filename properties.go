@@ -0,0 +1,128 @@
+package queue
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"unicode/utf16"
+)
+
+// Marks property values holding base64 encoded binary data.
+const binaryPropertyPrefix = "base64:"
+
+// SetBytes sets the property to binary data. Headers can't carry raw bytes,
+// so the value is stored base64 encoded and prefixed with a "base64:" type marker.
+func (p Properties) SetBytes(key string, value []byte) {
+	p.Set(key, binaryPropertyPrefix+base64.StdEncoding.EncodeToString(value))
+}
+
+// GetBytes gets binary data stored with SetBytes.
+// It returns nil if the property is not set and an error if the property
+// doesn't hold binary data.
+func (p Properties) GetBytes(key string) ([]byte, error) {
+
+	value := p.Get(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(value, binaryPropertyPrefix) {
+		return nil, fmt.Errorf("Property %s doesn't hold binary data", key)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, binaryPropertyPrefix))
+	if err != nil {
+		return nil, wrap(err, "Property "+key+" decode failed")
+	}
+
+	return b, nil
+}
+
+// Returns all values of a custom property of a received message. A property received
+// in several headers has one value per header, while Properties and GetProperty hold
+// the values joined with ", " as for repeated HTTP headers. Returns nil if the
+// property is not set.
+func (m *Message) PropertyValues(key string) []string {
+
+	key = textproto.CanonicalMIMEHeaderKey(key)
+	if values, ok := m.multiValued[key]; ok && m.Properties[key] == strings.Join(values, ", ") {
+		return append([]string(nil), values...)
+	}
+
+	if value, ok := m.Properties[key]; ok {
+		return []string{value}
+	}
+	return nil
+}
+
+// Sets a received custom property, remembering the individual values if there are several.
+func (m *Message) setPropertyValues(key string, values []string) {
+
+	m.SetProperty(key, strings.Join(values, ", "))
+
+	if len(values) > 1 {
+		if m.multiValued == nil {
+			m.multiValued = map[string][]string{}
+		}
+		m.multiValued[textproto.CanonicalMIMEHeaderKey(key)] = values
+	}
+}
+
+// Encodes a custom property value the way Service Bus expects string values:
+// enclosed in double quotes with quotes, backslashes, control and non-ASCII
+// characters escaped as in JSON, so that the header stays plain ASCII.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/message-headers-and-properties
+func quotePropertyValue(value string) string {
+
+	const hex = "0123456789abcdef"
+
+	var b strings.Builder
+	b.WriteByte('"')
+
+	writeEscaped := func(r rune) {
+		b.WriteString(`\u`)
+		for shift := 12; shift >= 0; shift -= 4 {
+			b.WriteByte(hex[(r>>uint(shift))&0xF])
+		}
+	}
+
+	for _, r := range value {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7F:
+			writeEscaped(r)
+		case r > 0xFFFF:
+			r1, r2 := utf16.EncodeRune(r)
+			writeEscaped(r1)
+			writeEscaped(r2)
+		case r > 0x7F:
+			writeEscaped(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	b.WriteByte('"')
+	return b.String()
+}
+
+// Decodes a custom property value received from Service Bus. Quoted string values
+// are unescaped; other values such as numbers and booleans are returned as is.
+func unquotePropertyValue(value string) string {
+
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+
+	var s string
+	if err := json.Unmarshal([]byte(value), &s); err != nil {
+		return strings.Trim(value, "\"")
+	}
+
+	return s
+}
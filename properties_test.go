@@ -0,0 +1,124 @@
+package queue
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func Test_quotePropertyValue(t *testing.T) {
+
+	tests := []struct {
+		value  string
+		quoted string
+	}{
+		{"Value", `"Value"`},
+		{"", `""`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\temp`, `"C:\\temp"`},
+		{"line1\nline2", `"line1\u000aline2"`},
+		{"café", `"caf\u00e9"`},
+		{"😀", `"\ud83d\ude00"`},
+	}
+
+	for _, test := range tests {
+		quoted := quotePropertyValue(test.value)
+
+		if quoted != test.quoted {
+			t.Fatalf("Expected quoted value %s but got %s", test.quoted, quoted)
+		}
+
+		if value := unquotePropertyValue(quoted); value != test.value {
+			t.Fatalf("Expected round-tripped value %s but got %s", test.value, value)
+		}
+	}
+}
+
+func Test_unquotePropertyValue(t *testing.T) {
+
+	tests := []struct {
+		received string
+		value    string
+	}{
+		{"Value", "Value"},
+		{"123", "123"},
+		{"true", "true"},
+		{`"Value"`, "Value"},
+		{`"`, `"`},
+		{`"bad \x escape"`, `bad \x escape`},
+	}
+
+	for _, test := range tests {
+		if value := unquotePropertyValue(test.received); value != test.value {
+			t.Fatalf("Expected value %s but got %s", test.value, value)
+		}
+	}
+}
+
+func Test_Properties_Bytes(t *testing.T) {
+
+	p := Properties{}
+	value := []byte{0, 1, 2, 0xFF, '\n', '"'}
+
+	p.SetBytes("Binary", value)
+
+	if p.Get("Binary") != "base64:AAEC/woi" {
+		t.Fatalf("Expected stored value %s but got %s", "base64:AAEC/woi", p.Get("Binary"))
+	}
+
+	b, err := p.GetBytes("binary")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b, value) {
+		t.Fatalf("Expected value %v but got %v", value, b)
+	}
+
+	if b, err := p.GetBytes("Missing"); b != nil || err != nil {
+		t.Fatalf("Expected nil value for missing property but got %v, %v", b, err)
+	}
+
+	p.Set("Text", "Value")
+	if _, err := p.GetBytes("Text"); err == nil {
+		t.Fatal("Expected error for property without binary data")
+	}
+
+	p.Set("Broken", "base64:!!!")
+	if _, err := p.GetBytes("Broken"); err == nil {
+		t.Fatal("Expected error for property with invalid base64 data")
+	}
+}
+
+func Test_PropertyValues(t *testing.T) {
+
+	m := &Message{Properties: Properties{}}
+	parseHeaders(m, &http.Response{Header: http.Header{
+		"Tags":   []string{`"eu"`, `"priority, high"`},
+		"Tenant": []string{`"contoso"`},
+	}})
+
+	if m.GetProperty("Tags") != "eu, priority, high" {
+		t.Fatalf("Expected joined values but got %q", m.GetProperty("Tags"))
+	}
+
+	expected := []string{"eu", "priority, high"}
+	if values := m.Clone().PropertyValues("tags"); !reflect.DeepEqual(values, expected) {
+		t.Fatalf("Expected values %q but got %q", expected, values)
+	}
+
+	if values := m.PropertyValues("Tenant"); !reflect.DeepEqual(values, []string{"contoso"}) {
+		t.Fatalf("Expected single value but got %q", values)
+	}
+
+	m.SetProperty("Tags", "none")
+	if values := m.PropertyValues("Tags"); !reflect.DeepEqual(values, []string{"none"}) {
+		t.Fatalf("Expected overwritten value but got %q", values)
+	}
+
+	if m.PropertyValues("Missing") != nil {
+		t.Fatalf("Expected nil for missing property")
+	}
+}
@@ -0,0 +1,64 @@
+package queue
+
+import (
+	"fmt"
+	"time"
+)
+
+// Executes a Redis command and returns its reply.
+// The signature matches redigo's redis.Conn; other clients are easy to adapt.
+type RedisConn interface {
+	Do(commandName string, args ...interface{}) (reply interface{}, err error)
+}
+
+// DedupeStore backed by Redis, so deduplication works across horizontally
+// scaled consumer replicas. Ids are stored with SET NX and an expiry of Window.
+type RedisDedupeStore struct {
+	// Connection to Redis. Must be safe for concurrent use, e.g. a wrapper
+	// taking a connection from a pool for every command.
+	Conn RedisConn
+
+	// Prefix of the keys, e.g. "orders:processed:".
+	Prefix string
+
+	// How long processed ids are remembered, DefaultDedupeWindow if zero.
+	Window time.Duration
+}
+
+func (s *RedisDedupeStore) Seen(id string) (bool, error) {
+
+	reply, err := s.Conn.Do("EXISTS", s.Prefix+id)
+	if err != nil {
+		return false, wrap(err, "Redis EXISTS failed")
+	}
+
+	switch n := reply.(type) {
+	case int64:
+		return n > 0, nil
+	case int:
+		return n > 0, nil
+	}
+
+	return false, fmt.Errorf("Unexpected Redis EXISTS reply %v", reply)
+}
+
+func (s *RedisDedupeStore) Mark(id string) error {
+
+	window := s.Window
+	if window <= 0 {
+		window = DefaultDedupeWindow
+	}
+
+	// Redis rejects expiries below a millisecond
+	expiry := int64(window / time.Millisecond)
+	if expiry < 1 {
+		expiry = 1
+	}
+
+	_, err := s.Conn.Do("SET", s.Prefix+id, 1, "PX", expiry, "NX")
+	if err != nil {
+		return wrap(err, "Redis SET failed")
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// In-memory stand-in for a Redis connection supporting EXISTS and SET.
+type fakeRedisConn struct {
+	commands []string
+	keys     map[string]bool
+}
+
+func (c *fakeRedisConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+
+	c.commands = append(c.commands, fmt.Sprint(append([]interface{}{commandName}, args...)...))
+
+	key := args[0].(string)
+	switch commandName {
+	case "EXISTS":
+		if c.keys[key] {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case "SET":
+		if c.keys[key] {
+			return nil, nil
+		}
+		c.keys[key] = true
+		return "OK", nil
+	}
+
+	return nil, fmt.Errorf("unknown command %s", commandName)
+}
+
+func Test_RedisDedupeStore(t *testing.T) {
+
+	conn := &fakeRedisConn{keys: map[string]bool{}}
+	s := &RedisDedupeStore{Conn: conn, Prefix: "processed:", Window: time.Minute}
+
+	if seen, err := s.Seen("1"); seen || err != nil {
+		t.Fatalf("Expected id not to be seen but got %v, %v", seen, err)
+	}
+
+	if err := s.Mark("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if seen, err := s.Seen("1"); !seen || err != nil {
+		t.Fatalf("Expected id to be seen but got %v, %v", seen, err)
+	}
+
+	expected := fmt.Sprint([]string{
+		fmt.Sprint("EXISTS", "processed:1"),
+		fmt.Sprint("SET", "processed:1", 1, "PX", int64(60000), "NX"),
+		fmt.Sprint("EXISTS", "processed:1"),
+	})
+
+	if fmt.Sprint(conn.commands) != expected {
+		t.Fatalf("Expected commands %s but got %v", expected, conn.commands)
+	}
+}
+
+func Test_RedisDedupeStore_defaultWindow(t *testing.T) {
+
+	conn := &fakeRedisConn{keys: map[string]bool{}}
+	s := &RedisDedupeStore{Conn: conn}
+
+	if err := s.Mark("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fmt.Sprint("SET", "1", 1, "PX", int64(DefaultDedupeWindow/time.Millisecond), "NX")
+	if len(conn.commands) != 1 || conn.commands[0] != expected {
+		t.Fatalf("Expected command %s but got %v", expected, conn.commands)
+	}
+}
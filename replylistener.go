@@ -0,0 +1,164 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Returned by ReplyListener.Request when the registration for a reply expired.
+var ErrReplyExpired = errors.New("Reply expired")
+
+// Pause of ReplyListener.Listen after a failed receive.
+var replyErrorBackoff = time.Second
+
+// Consumes a reply queue and hands replies over to the callers waiting for them,
+// matched by CorrelationId. It is the consumer half of the request/reply pattern
+// for any number of concurrent callers sharing one reply queue.
+//
+// Replies nobody waits for, e.g. because the caller gave up, are deleted.
+type ReplyListener struct {
+	// Queue the replies arrive on.
+	Replies *QueueClient
+
+	mu      sync.Mutex
+	waiting map[string]*pendingReply
+}
+
+type pendingReply struct {
+	reply   chan *Message
+	expires time.Time
+}
+
+func NewReplyListener(replies *QueueClient) *ReplyListener {
+
+	return &ReplyListener{
+		Replies: replies,
+		waiting: map[string]*pendingReply{},
+	}
+}
+
+// Registers interest in the reply with the given correlation id. Must be called before
+// the request is sent. The reply is delivered on the returned channel, which is closed
+// without a value if no reply arrives within ttl.
+func (l *ReplyListener) Expect(correlationId string, ttl time.Duration) <-chan *Message {
+
+	p := &pendingReply{
+		reply:   make(chan *Message, 1),
+		expires: time.Now().Add(ttl),
+	}
+
+	l.mu.Lock()
+	l.waiting[correlationId] = p
+	l.mu.Unlock()
+
+	return p.reply
+}
+
+// Removes the registration for the reply with the given correlation id.
+func (l *ReplyListener) Cancel(correlationId string) {
+	l.mu.Lock()
+	delete(l.waiting, correlationId)
+	l.mu.Unlock()
+}
+
+// Sends the request with ReplyTo set to the listener's queue and waits for the reply
+// until ctx is done. The listener must be running, see Listen.
+func (l *ReplyListener) Request(ctx context.Context, sender Sender, msg *Message) (*Message, error) {
+
+	if err := prepareRequest(msg, l.Replies.QueueName); err != nil {
+		return nil, err
+	}
+
+	ttl := time.Duration(1<<63 - 1)
+	if deadline, ok := ctx.Deadline(); ok {
+		ttl = time.Until(deadline)
+	}
+
+	reply := l.Expect(msg.CorrelationId, ttl)
+	defer l.Cancel(msg.CorrelationId)
+
+	if err := sender.SendMessage(msg); err != nil {
+		return nil, wrap(err, "Sending request failed")
+	}
+
+	select {
+	case r, ok := <-reply:
+		if !ok {
+			return nil, ErrReplyExpired
+		}
+		return r, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Receives replies and dispatches them to waiting callers until ctx is done
+// or the reply queue client is closed. Receive failures are logged and retried
+// after replyErrorBackoff.
+func (l *ReplyListener) Listen(ctx context.Context) error {
+
+	for {
+		l.expire(time.Now())
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		reply, err := l.Replies.receive(ctx, replyPollTimeout(ctx, l.Replies.Timeout))
+
+		if _, ok := err.(NoMessagesAvailableError); ok {
+			continue
+		}
+
+		if err == ErrClientClosed {
+			return err
+		}
+
+		if err != nil {
+			logger.Error("Receiving reply failed", err)
+
+			select {
+			case <-time.After(replyErrorBackoff):
+			case <-ctx.Done():
+			}
+			continue
+		}
+
+		l.dispatch(reply)
+	}
+}
+
+func (l *ReplyListener) dispatch(reply *Message) {
+
+	l.mu.Lock()
+	p, ok := l.waiting[reply.CorrelationId]
+	delete(l.waiting, reply.CorrelationId)
+	l.mu.Unlock()
+
+	if err := l.Replies.DeleteMessage(reply); err != nil {
+		logger.Error("Completing reply failed", err)
+	}
+
+	if !ok {
+		logger.Debug("Dropping reply nobody waits for ", reply.CorrelationId)
+		return
+	}
+
+	p.reply <- reply
+}
+
+// Closes the channels of registrations which expired by now.
+func (l *ReplyListener) expire(now time.Time) {
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for id, p := range l.waiting {
+		if now.After(p.expires) {
+			delete(l.waiting, id)
+			close(p.reply)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_ReplyListener(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	// correlation ids of sent requests waiting to be answered
+	requests := make(chan string, 10)
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == "POST" && strings.HasPrefix(req.URL.Path, "/requests/"):
+			p := brokerProperties{}
+			json.Unmarshal([]byte(req.Header.Get(headerBrokerProperties)), &p)
+			requests <- p.CorrelationId
+			return respondWith(201, "")(req)
+		case req.Method == "POST":
+			select {
+			case id := <-requests:
+				resp, _ := respondWith(200, "reply to "+id)(req)
+				resp.Header.Set(headerBrokerProperties, `{"MessageId":"`+id+`","LockToken":"lock","CorrelationId":"`+id+`"}`)
+				return resp, nil
+			case <-time.After(10 * time.Millisecond):
+				return respondWith(204, "")(req)
+			}
+		default:
+			return respondWith(200, "")(req)
+		}
+	}))
+
+	sender := &QueueClient{Namespace: "test", QueueName: "requests"}
+	l := NewReplyListener(&QueueClient{Namespace: "test", QueueName: "replies", Timeout: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	listening := make(chan error, 1)
+	go func() { listening <- l.Listen(ctx) }()
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+
+			reqCtx, reqCancel := context.WithTimeout(context.Background(), time.Second)
+			defer reqCancel()
+
+			msg := NewMessage([]byte("request"))
+			msg.CorrelationId = id
+
+			reply, err := l.Request(reqCtx, sender, msg)
+
+			if err != nil {
+				t.Errorf("Request %s failed: %v", id, err)
+				return
+			}
+
+			if string(reply.Body) != "reply to "+id {
+				t.Errorf("Expected reply to %s but got %s", id, string(reply.Body))
+			}
+		}(id)
+	}
+	wg.Wait()
+
+	cancel()
+	if err := <-listening; err != context.Canceled {
+		t.Fatalf("Expected listener to stop with %v but got %v", context.Canceled, err)
+	}
+}
+
+func Test_ReplyListener_expire(t *testing.T) {
+
+	l := NewReplyListener(&QueueClient{})
+
+	expired := l.Expect("a", time.Millisecond)
+	pending := l.Expect("b", time.Hour)
+
+	l.expire(time.Now().Add(time.Second))
+
+	if _, ok := <-expired; ok {
+		t.Fatal("Expected expired registration to be closed")
+	}
+
+	select {
+	case <-pending:
+		t.Fatal("Expected pending registration to stay open")
+	default:
+	}
+
+	l.Cancel("b")
+
+	if len(l.waiting) != 0 {
+		t.Fatalf("Expected no registrations but got %v", len(l.waiting))
+	}
+}
+
+func Test_ReplyListener_backoff(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	defer func(backoff time.Duration) { replyErrorBackoff = backoff }(replyErrorBackoff)
+	replyErrorBackoff = 50 * time.Millisecond
+
+	var mu sync.Mutex
+	attempts := 0
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		return respondWith(500, "")(req)
+	}))
+
+	l := NewReplyListener(&QueueClient{Namespace: "test", QueueName: "replies"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+
+	if err := l.Listen(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Expected error %v but got %v", context.DeadlineExceeded, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts < 2 || attempts > 3 {
+		t.Fatalf("Expected 2 or 3 receive attempts but got %v", attempts)
+	}
+}
@@ -0,0 +1,227 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Name of the custom property counting how many retry tiers a message went through.
+const RetryAttemptProperty = "Retry-Attempt"
+
+// Name of the custom property holding the MessageId of the message a retried copy was
+// made from. Copies get a new MessageId, as the original one is recorded as processed
+// by dedupe stores and the duplicate detection of the retry queues.
+const OriginalMessageIdProperty = "Original-Message-Id"
+
+// Returned by RetryPolicy.Retry if the policy has no DeadLetter queue.
+var ErrNoDeadLetter = errors.New("Retry policy has no DeadLetter queue")
+
+// A retry queue failed messages are scheduled into.
+type RetryTier struct {
+	// Queue receiving the retried messages, which should forward them back
+	// to the original queue once they become due, see EnsureRetryQueues.
+	Queue Sender
+
+	// How long the message is delayed before it's delivered again.
+	Delay time.Duration
+}
+
+// RetryPolicy routes failed messages to tiered retry queues by attempt number,
+// e.g. retry-1m, then retry-10m, the standard delayed-retry topology on Service Bus.
+type RetryPolicy struct {
+	// Tiers used for the first, second, ... retry of a message.
+	Tiers []RetryTier
+
+	// Queue receiving messages which failed after the last tier. Required, as the
+	// dead-letter queue of a queue can't be sent to, and unlocking such messages
+	// would only retry them without delay until MaxDeliveryCount is reached.
+	DeadLetter Sender
+
+	// Optional hook receiving an AuditEvent for every attempt to move a message
+	// to DeadLetter, for compliance records of message disposal.
+	Audit func(event AuditEvent)
+}
+
+// Receives the next message and processes it with handler. Messages are completed
+// if handler succeeds and retried according to the policy if it fails, in which case
+// the handler error is returned.
+//
+// Returns NoMessagesAvailableError if there was nothing to process.
+func (p *RetryPolicy) Process(r Receiver, handler func(msg *Message) error) error {
+
+	msg, err := r.GetMessage()
+	if err != nil {
+		return err
+	}
+
+	if err := handler(msg); err != nil {
+		if rerr := p.Retry(r, msg); rerr != nil {
+			logger.Error("Routing failed message to retry queue failed", rerr)
+		}
+		return err
+	}
+
+	return r.DeleteMessage(msg)
+}
+
+// Routes a received message which failed processing: a copy is scheduled into the
+// retry tier for its attempt number and the original completed. After the last tier
+// the message goes to DeadLetter. If the policy has no DeadLetter queue, the message
+// is unlocked and ErrNoDeadLetter returned.
+func (p *RetryPolicy) Retry(r Receiver, msg *Message) error {
+
+	if p.DeadLetter == nil {
+		if err := r.UnlockMessage(msg); err != nil {
+			logger.Error("Unlocking message failed", err)
+		}
+		return ErrNoDeadLetter
+	}
+
+	attempt, _ := strconv.Atoi(msg.GetProperty(RetryAttemptProperty))
+
+	var target Sender
+	retry, err := retryCopy(msg)
+	if err != nil {
+		if uerr := r.UnlockMessage(msg); uerr != nil {
+			logger.Error("Unlocking message failed", uerr)
+		}
+		return err
+	}
+
+	if attempt < len(p.Tiers) {
+		tier := p.Tiers[attempt]
+		target = tier.Queue
+		retry.ScheduledEnqueueTimeUtc = time.Now().UTC().Add(tier.Delay)
+		retry.SetProperty(RetryAttemptProperty, strconv.Itoa(attempt+1))
+	} else {
+		target = p.DeadLetter
+	}
+
+	err = target.SendMessage(retry)
+
+	if p.Audit != nil && attempt >= len(p.Tiers) {
+		p.Audit(newAuditEvent(AuditDeadLetter, msg, err))
+	}
+
+	if err != nil {
+		if uerr := r.UnlockMessage(msg); uerr != nil {
+			logger.Error("Unlocking message failed", uerr)
+		}
+		return wrap(err, "Sending message to retry queue failed")
+	}
+
+	return r.DeleteMessage(msg)
+}
+
+// Returns a copy of a received message suitable for sending, without its delivery state
+// and with a new MessageId. The id of the first message is kept in OriginalMessageIdProperty.
+func retryCopy(msg *Message) (*Message, error) {
+
+	id, err := newUuid()
+	if err != nil {
+		return nil, err
+	}
+
+	c := msg.Clone()
+	c.Id = id
+	if c.GetProperty(OriginalMessageIdProperty) == "" && msg.Id != "" {
+		c.SetProperty(OriginalMessageIdProperty, msg.Id)
+	}
+	c.LockToken = ""
+	c.LockedUntilUtc = time.Time{}
+	c.DeliveryCount = 0
+	c.SequenceNumber = 0
+	c.EnqueuedTimeUtc = time.Time{}
+	c.ScheduledEnqueueTimeUtc = time.Time{}
+	c.SystemProperties = nil
+	return c, nil
+}
+
+// Creates or updates a retry queue per delay, named after the client's queue, e.g.
+// orders-retry-1m, and returns the policy routing failed messages through them.
+// Retry queues forward messages to the client's queue as soon as their scheduled
+// enqueue time is due; messages expiring there after the delay are forwarded as well.
+// Messages failing after the last tier go to the queue named e.g. orders-dead-letter.
+func (q *QueueClient) EnsureRetryQueues(ctx context.Context, delays ...time.Duration) (*RetryPolicy, error) {
+
+	deadLetter := q.forQueue(q.QueueName + "-dead-letter")
+	if _, err := deadLetter.EnsureQueue(ctx, QueueDescription{}); err != nil {
+		return nil, wrap(err, "Ensuring dead-letter queue "+deadLetter.QueueName+" failed")
+	}
+
+	policy := &RetryPolicy{DeadLetter: deadLetter}
+
+	for _, delay := range delays {
+		retry := q.forQueue(retryQueueName(q.QueueName, delay))
+
+		_, err := retry.EnsureQueue(ctx, QueueDescription{
+			DefaultMessageTimeToLive:         delay,
+			DeadLetteringOnMessageExpiration: true,
+			ForwardTo:                        q.QueueName,
+			ForwardDeadLetteredMessagesTo:    q.QueueName,
+		})
+		if err != nil {
+			return nil, wrap(err, "Ensuring retry queue "+retry.QueueName+" failed")
+		}
+
+		policy.Tiers = append(policy.Tiers, RetryTier{Queue: retry, Delay: delay})
+	}
+
+	return policy, nil
+}
+
+// Returns the name of the retry queue for the delay, e.g. orders-retry-1m or orders-retry-1h30m.
+func retryQueueName(queueName string, delay time.Duration) string {
+
+	d := delay.String()
+	if strings.HasSuffix(d, "m0s") {
+		d = d[:len(d)-2]
+	}
+	if strings.HasSuffix(d, "h0m") {
+		d = d[:len(d)-2]
+	}
+	return queueName + "-retry-" + d
+}
+
+// Returns a client for another queue of the same namespace, with the same credentials and settings.
+func (q *QueueClient) forQueue(queueName string) *QueueClient {
+
+	keyName, keyValue := q.credentials()
+
+	return &QueueClient{
+		Namespace:          q.Namespace,
+		KeyName:            keyName,
+		KeyValue:           keyValue,
+		RequireFIPS:        q.RequireFIPS,
+		SecondaryKeyName:   q.SecondaryKeyName,
+		SecondaryKeyValue:  q.SecondaryKeyValue,
+		QueueName:          queueName,
+		Timeout:            q.Timeout,
+		HttpTimeout:        q.HttpTimeout,
+		DialContext:        q.DialContext,
+		Resolver:           q.Resolver,
+		DefaultContentType: q.DefaultContentType,
+		SasUriCasing:       q.SasUriCasing,
+		DerivePartitionKey: q.DerivePartitionKey,
+		GatewayURL:         q.GatewayURL,
+		Headers:            q.Headers,
+
+		MaxResponseBodySize:    q.MaxResponseBodySize,
+		SchemaRegistry:         q.SchemaRegistry,
+		HedgeDelay:             q.HedgeDelay,
+		Signer:                 q.Signer,
+		Transforms:             q.Transforms,
+		Codecs:                 q.Codecs,
+		SignatureKeys:          q.SignatureKeys,
+		Dedupe:                 q.Dedupe,
+		Tracker:                q.Tracker,
+		OnLockExpiring:         q.OnLockExpiring,
+		LockExpiringLead:       q.LockExpiringLead,
+		Audit:                  q.Audit,
+		PropagateCorrelationId: q.PropagateCorrelationId,
+		NeverLogBody:           q.NeverLogBody,
+	}
+}
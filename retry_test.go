@@ -0,0 +1,249 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeReceiver struct {
+	next      *Message
+	completed []*Message
+	unlocked  []*Message
+}
+
+func (r *fakeReceiver) GetMessage() (*Message, error) {
+	return r.next, nil
+}
+
+func (r *fakeReceiver) UnlockMessage(msg *Message) error {
+	r.unlocked = append(r.unlocked, msg)
+	return nil
+}
+
+func (r *fakeReceiver) DeleteMessage(msg *Message) error {
+	r.completed = append(r.completed, msg)
+	return nil
+}
+
+func Test_RetryPolicy(t *testing.T) {
+
+	retry1m, retry10m, deadLetter := &recordingSender{}, &recordingSender{}, &recordingSender{}
+	policy := &RetryPolicy{Tiers: []RetryTier{{retry1m, time.Minute}, {retry10m, 10 * time.Minute}}, DeadLetter: deadLetter}
+
+	msg := NewMessage([]byte("order"))
+	msg.Id = "abc"
+	msg.LockToken = "token"
+	msg.DeliveryCount = 3
+
+	r := &fakeReceiver{next: msg}
+	failure := errors.New("failed")
+	fail := func(msg *Message) error { return failure }
+
+	if err := policy.Process(r, fail); err != failure {
+		t.Fatalf("Expected handler error but got %v", err)
+	}
+
+	if len(retry1m.sent) != 1 || len(r.completed) != 1 {
+		t.Fatalf("Expected message moved to first tier but got %v sent and %v completed", len(retry1m.sent), len(r.completed))
+	}
+
+	retried := retry1m.sent[0]
+	if retried.Id == "abc" || retried.GetProperty(OriginalMessageIdProperty) != "abc" || retried.LockToken != "" || retried.GetProperty(RetryAttemptProperty) != "1" {
+		t.Fatalf("Expected first retry of message abc but got %+v", retried)
+	}
+
+	if delay := time.Until(retried.ScheduledEnqueueTimeUtc); delay < 59*time.Second || delay > time.Minute {
+		t.Fatalf("Expected message scheduled in a minute but got %v", delay)
+	}
+
+	r.next = retried
+	policy.Process(r, fail)
+	if len(retry10m.sent) != 1 || retry10m.sent[0].GetProperty(RetryAttemptProperty) != "2" {
+		t.Fatalf("Expected message moved to second tier")
+	}
+
+	if retry10m.sent[0].GetProperty(OriginalMessageIdProperty) != "abc" {
+		t.Fatalf("Expected id of the first message to be kept but got %v", retry10m.sent[0].GetProperty(OriginalMessageIdProperty))
+	}
+
+	r.next = retry10m.sent[0]
+	policy.Process(r, fail)
+	if len(deadLetter.sent) != 1 || len(r.completed) != 3 {
+		t.Fatalf("Expected message moved to dead-letter queue after last tier")
+	}
+
+	r.next = msg
+	if err := policy.Process(r, func(msg *Message) error { return nil }); err != nil || len(r.completed) != 4 {
+		t.Fatalf("Expected handled message to be completed but got %v", err)
+	}
+}
+
+func Test_RetryPolicy_noDeadLetter(t *testing.T) {
+
+	policy := &RetryPolicy{Tiers: []RetryTier{{&recordingSender{}, time.Minute}}}
+
+	msg := NewMessage([]byte("order"))
+	r := &fakeReceiver{}
+
+	if err := policy.Retry(r, msg); err != ErrNoDeadLetter {
+		t.Fatalf("Expected error %v but got %v", ErrNoDeadLetter, err)
+	}
+
+	if len(r.unlocked) != 1 || len(r.completed) != 0 {
+		t.Fatalf("Expected message unlocked but got %v unlocked and %v completed", len(r.unlocked), len(r.completed))
+	}
+}
+
+func Test_EnsureRetryQueues(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	entries := map[string]string{}
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		switch req.Method {
+		case "GET":
+			if entry, ok := entries[req.URL.Path]; ok {
+				return respondWith(200, entry)(req)
+			}
+			return respondWith(200, emptyFeedXml)(req)
+		case "PUT":
+			body, _ := ioutil.ReadAll(req.Body)
+			entries[req.URL.Path] = string(body)
+			return respondWith(201, string(body))(req)
+		}
+		return respondWith(400, "")(req)
+	}))
+
+	policy, err := q.EnsureRetryQueues(context.Background(), time.Minute, 90*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(policy.Tiers) != 2 || policy.Tiers[1].Delay != 90*time.Minute {
+		t.Fatalf("Expected 2 tiers but got %+v", policy.Tiers)
+	}
+
+	names := []string{"test-retry-1m", "test-retry-1h30m"}
+	for i, name := range names {
+		if policy.Tiers[i].Queue.(*QueueClient).QueueName != name {
+			t.Fatalf("Expected retry queue %v but got %v", name, policy.Tiers[i].Queue.(*QueueClient).QueueName)
+		}
+
+		entry := entries["/"+name]
+		if !strings.Contains(entry, "<ForwardTo>test</ForwardTo>") || !strings.Contains(entry, "<ForwardDeadLetteredMessagesTo>test</ForwardDeadLetteredMessagesTo>") {
+			t.Fatalf("Expected %v to forward to the base queue but got %s", name, entry)
+		}
+	}
+
+	if policy.DeadLetter.(*QueueClient).QueueName != "test-dead-letter" || entries["/test-dead-letter"] == "" {
+		t.Fatalf("Expected dead-letter queue test-dead-letter to be created")
+	}
+
+	if !strings.Contains(entries["/test-retry-1m"], "<DefaultMessageTimeToLive>PT60S</DefaultMessageTimeToLive>") {
+		t.Fatalf("Expected TTL of the delay but got %s", entries["/test-retry-1m"])
+	}
+}
+
+func Test_RetryPolicy_keepsSendSettings(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	cli := &QueueClient{
+		Namespace:     "test",
+		KeyName:       "key",
+		KeyValue:      "keyvalue",
+		QueueName:     "test",
+		Signer:        &MessageSigner{KeyId: "k1", Key: []byte("secret")},
+		SignatureKeys: staticKeys{"k1": []byte("secret")},
+		Transforms:    []BodyTransform{GzipTransform{}},
+	}
+
+	var sent *http.Request
+	var sentBody []byte
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "POST" {
+			sent = req
+			sentBody, _ = ioutil.ReadAll(req.Body)
+			return respondWith(201, "")(req)
+		}
+		return respondWith(200, "")(req)
+	}))
+
+	policy := &RetryPolicy{Tiers: []RetryTier{{cli.forQueue("test-retry-1m"), time.Minute}}, DeadLetter: &recordingSender{}}
+
+	msg := NewMessage([]byte("order"))
+	msg.LockToken = "token"
+	r := &fakeReceiver{next: msg}
+	policy.Process(r, func(msg *Message) error { return errors.New("failed") })
+
+	if sent == nil || !strings.HasPrefix(sent.URL.Path, "/test-retry-1m/") {
+		t.Fatalf("Expected message sent to the retry queue but got %v", sent)
+	}
+
+	if sent.Header.Get(headerContentEncoding) != ContentEncodingGzip || sent.Header.Get(SignatureProperty) == "" {
+		t.Fatalf("Expected retried message compressed and signed but got %v", sent.Header)
+	}
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		resp, _ := respondWith(200, string(sentBody))(req)
+		resp.Header = sent.Header
+		return resp, nil
+	}))
+
+	received, err := cli.GetMessage()
+	if err != nil {
+		t.Fatalf("Expected forwarded message to verify but got %v", err)
+	}
+
+	if string(received.Body) != "order" {
+		t.Fatalf("Expected original body but got %s", received.Body)
+	}
+}
+
+func Test_RetryPolicy_dedupe(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	// ids of the messages in the queue, the retried copy is appended once sent
+	queued := []string{"abc"}
+	retry := &recordingSender{}
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "DELETE" {
+			return respondWith(200, "")(req)
+		}
+
+		if len(retry.sent) > 0 && len(queued) == 0 {
+			queued = append(queued, retry.sent[0].Id)
+		}
+		if len(queued) == 0 {
+			return respondWith(204, "")(req)
+		}
+
+		id := queued[0]
+		queued = queued[1:]
+
+		resp, _ := respondWith(200, "order")(req)
+		resp.Header.Set(headerBrokerProperties, `{"MessageId":"`+id+`","LockToken":"lock"}`)
+		return resp, nil
+	}))
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test", Dedupe: NewMemoryDedupeStore(10, time.Hour)}
+	policy := &RetryPolicy{Tiers: []RetryTier{{retry, time.Minute}}, DeadLetter: &recordingSender{}}
+
+	policy.Process(cli, func(msg *Message) error { return errors.New("failed") })
+
+	handled := 0
+	if err := policy.Process(cli, func(msg *Message) error { handled++; return nil }); err != nil {
+		t.Fatalf("Expected retried message to be delivered again but got %v", err)
+	}
+
+	if handled != 1 {
+		t.Fatalf("Expected retried message to be handled once but got %v", handled)
+	}
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// Sends a request and waits until the correlated reply arrives on the replies queue
+// or ctx is done. ReplyTo and CorrelationId of the request are set if empty, and the
+// responder is expected to copy the CorrelationId onto its reply.
+// The reply is deleted from the replies queue before it is returned.
+// Replies are long-polled for replies.Timeout seconds, or defaultReplyPollTimeout if unset.
+//
+// Replies to other requests are unlocked, so the replies queue should be dedicated
+// to the caller.
+func Request(ctx context.Context, sender Sender, replies *QueueClient, msg *Message) (*Message, error) {
+
+	if err := prepareRequest(msg, replies.QueueName); err != nil {
+		return nil, err
+	}
+
+	if err := sender.SendMessage(msg); err != nil {
+		return nil, wrap(err, "Sending request failed")
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		reply, err := replies.receive(ctx, replyPollTimeout(ctx, replies.Timeout))
+
+		if _, ok := err.(NoMessagesAvailableError); ok {
+			continue
+		}
+
+		if err != nil {
+			return nil, wrap(err, "Receiving reply failed")
+		}
+
+		if reply.CorrelationId != msg.CorrelationId {
+			if err := replies.UnlockMessage(reply); err != nil {
+				logger.Error("Unlocking uncorrelated reply failed", err)
+			}
+			continue
+		}
+
+		if err := replies.DeleteMessage(reply); err != nil {
+			return nil, wrap(err, "Completing reply failed")
+		}
+
+		return reply, nil
+	}
+}
+
+// Sets ReplyTo and CorrelationId of a request unless they are set already.
+func prepareRequest(msg *Message, replyTo string) error {
+
+	if msg.ReplyTo == "" {
+		msg.ReplyTo = replyTo
+	}
+
+	if msg.CorrelationId == "" {
+		id, err := newUuid()
+		if err != nil {
+			return err
+		}
+		msg.CorrelationId = id
+	}
+
+	return nil
+}
+
+// Server-side wait in seconds for reply polls of a client without a Timeout.
+const defaultReplyPollTimeout = 30
+
+// Returns the server-side wait for the next poll, bounded by the context deadline.
+// The wait is at least a second, so waiting for a reply never turns into a busy loop;
+// the poll itself is cancelled with the context.
+func replyPollTimeout(ctx context.Context, timeout int) int {
+
+	if timeout <= 0 {
+		timeout = defaultReplyPollTimeout
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if remaining := int(time.Until(deadline) / time.Second); remaining < timeout {
+			timeout = remaining
+		}
+	}
+
+	if timeout < 1 {
+		return 1
+	}
+
+	return timeout
+}
@@ -0,0 +1,118 @@
+package queue
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Request(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	replies := []string{"other", "abc"}
+	var sent *http.Request
+	var settled []string
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		switch {
+		case req.Method == "POST" && strings.HasPrefix(req.URL.Path, "/requests/"):
+			sent = req
+			return respondWith(201, "")(req)
+		case req.Method == "POST":
+			if len(replies) == 0 {
+				return respondWith(204, "")(req)
+			}
+			correlationId := replies[0]
+			replies = replies[1:]
+
+			resp, _ := respondWith(200, "reply to "+correlationId)(req)
+			resp.Header.Set(headerBrokerProperties, `{"MessageId":"`+correlationId+`","LockToken":"lock","CorrelationId":"`+correlationId+`"}`)
+			return resp, nil
+		default:
+			settled = append(settled, req.Method+" "+req.URL.Path)
+			return respondWith(200, "")(req)
+		}
+	}))
+
+	requests := &QueueClient{Namespace: "test", QueueName: "requests"}
+	replyQueue := &QueueClient{Namespace: "test", QueueName: "replies", Timeout: 60}
+
+	msg := NewMessage([]byte("request"))
+	msg.CorrelationId = "abc"
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	reply, err := Request(ctx, requests, replyQueue, msg)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(reply.Body) != "reply to abc" {
+		t.Fatalf("Expected correlated reply but got %s", string(reply.Body))
+	}
+
+	if !strings.Contains(sent.Header.Get(headerBrokerProperties), `"ReplyTo":"replies"`) {
+		t.Fatalf("Expected ReplyTo to be set but got %s", sent.Header.Get(headerBrokerProperties))
+	}
+
+	expected := []string{"PUT /replies/messages/other/lock", "DELETE /replies/messages/abc/lock"}
+	if strings.Join(settled, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected settlements %v but got %v", expected, settled)
+	}
+}
+
+func Test_Request_timeout(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		if strings.HasPrefix(req.URL.Path, "/requests/") {
+			return respondWith(201, "")(req)
+		}
+		return respondWith(204, "")(req)
+	}))
+
+	requests := &QueueClient{Namespace: "test", QueueName: "requests"}
+	replyQueue := &QueueClient{Namespace: "test", QueueName: "replies", Timeout: 60}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	msg := NewMessage([]byte("request"))
+	if _, err := Request(ctx, requests, replyQueue, msg); err != context.DeadlineExceeded {
+		t.Fatalf("Expected error %v but got %v", context.DeadlineExceeded, err)
+	}
+
+	if msg.CorrelationId == "" {
+		t.Fatal("Expected CorrelationId to be generated")
+	}
+}
+
+func Test_replyPollTimeout(t *testing.T) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10500*time.Millisecond)
+	defer cancel()
+
+	if timeout := replyPollTimeout(ctx, 60); timeout != 10 {
+		t.Fatalf("Expected timeout %v but got %v", 10, timeout)
+	}
+
+	if timeout := replyPollTimeout(context.Background(), 60); timeout != 60 {
+		t.Fatalf("Expected timeout %v but got %v", 60, timeout)
+	}
+
+	if timeout := replyPollTimeout(context.Background(), 0); timeout != defaultReplyPollTimeout {
+		t.Fatalf("Expected timeout %v but got %v", defaultReplyPollTimeout, timeout)
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelShort()
+
+	if timeout := replyPollTimeout(short, 60); timeout != 1 {
+		t.Fatalf("Expected timeout %v but got %v", 1, timeout)
+	}
+}
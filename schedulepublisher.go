@@ -0,0 +1,108 @@
+package queue
+
+import (
+	"context"
+	"time"
+)
+
+// Number of occurrences SchedulePublisher schedules ahead by default.
+const DefaultScheduleAhead = 10
+
+// Sends recurring messages on a cron schedule, e.g. heartbeats or triggers of
+// periodic jobs driven through the queue. Messages for the next occurrences are
+// sent ahead with ScheduledEnqueueTimeUtc set and topped up as occurrences pass.
+//
+// Occurrences are scheduled again when the publisher restarts. Derive the MessageId
+// from the occurrence time and enable duplicate detection on the queue to drop them.
+type SchedulePublisher struct {
+	Sender Sender
+
+	Schedule *CronSchedule
+
+	// Location the schedule is evaluated in, defaults to UTC.
+	Location *time.Location
+
+	// Creates the message for an occurrence.
+	NewMessage func(at time.Time) *Message
+
+	// How many occurrences are scheduled ahead. Defaults to DefaultScheduleAhead.
+	Ahead int
+
+	// Optional callback for failed sends. Failures are logged if not set.
+	OnError func(err error)
+}
+
+// Creates a publisher sending the messages created by newMessage on the cron expression.
+func NewSchedulePublisher(sender Sender, cron string, newMessage func(at time.Time) *Message) (*SchedulePublisher, error) {
+
+	schedule, err := ParseCron(cron)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SchedulePublisher{Sender: sender, Schedule: schedule, NewMessage: newMessage}, nil
+}
+
+// Schedules the upcoming occurrences and tops them up until ctx is done.
+// Failed sends are retried a minute later.
+func (p *SchedulePublisher) Run(ctx context.Context) {
+
+	ahead := p.Ahead
+	if ahead <= 0 {
+		ahead = DefaultScheduleAhead
+	}
+
+	location := p.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	var pending []time.Time
+	next := p.Schedule.Next(time.Now().In(location))
+
+	for {
+		for len(pending) < ahead && !next.IsZero() {
+			if err := p.publish(next); err != nil {
+				break
+			}
+			pending = append(pending, next)
+			next = p.Schedule.Next(next)
+		}
+
+		wait := time.Minute
+		if len(pending) > 0 && len(pending) == ahead {
+			wait = time.Until(pending[0])
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		now := time.Now()
+		for len(pending) > 0 && !pending[0].After(now) {
+			pending = pending[1:]
+		}
+	}
+}
+
+func (p *SchedulePublisher) publish(at time.Time) error {
+
+	msg := p.NewMessage(at)
+	msg.ScheduledEnqueueTimeUtc = at.UTC()
+
+	err := p.Sender.SendMessage(msg)
+	if err == nil {
+		return nil
+	}
+
+	if p.OnError != nil {
+		p.OnError(err)
+	} else {
+		logger.Error("Scheduling message failed", err)
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_SchedulePublisher(t *testing.T) {
+
+	sender := &recordingSender{}
+	p, err := NewSchedulePublisher(sender, "*/5 * * * *", func(at time.Time) *Message {
+		msg := NewMessage([]byte("tick"))
+		msg.Id = "tick-" + at.Format(time.RFC3339)
+		return msg
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Ahead = 3
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		sender.mu.Lock()
+		n := len(sender.sent)
+		sender.mu.Unlock()
+		if n >= 3 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	if len(sender.sent) != 3 {
+		t.Fatalf("Expected 3 scheduled messages but got %v", len(sender.sent))
+	}
+
+	first := sender.sent[0].ScheduledEnqueueTimeUtc
+	if first.Minute()%5 != 0 || first.Location() != time.UTC || time.Until(first) > 5*time.Minute {
+		t.Fatalf("Expected first occurrence within 5 minutes but got %v", first)
+	}
+
+	for i, msg := range sender.sent {
+		expected := first.Add(time.Duration(i) * 5 * time.Minute)
+		if !msg.ScheduledEnqueueTimeUtc.Equal(expected) || msg.Id != "tick-"+expected.Format(time.RFC3339) {
+			t.Fatalf("Expected occurrence %v but got %v %v", expected, msg.ScheduledEnqueueTimeUtc, msg.Id)
+		}
+	}
+}
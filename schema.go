@@ -0,0 +1,44 @@
+package queue
+
+// Name of the custom property holding the schema id of the message body.
+const SchemaIdProperty = "Schema-Id"
+
+// SchemaRegistry resolves schema ids attached to messages and validates bodies against them,
+// enabling versioned payload evolution.
+type SchemaRegistry interface {
+	// Returns an error if the schema is unknown or the body doesn't conform to it.
+	Validate(schemaId string, body []byte) error
+}
+
+// Returned when a message body doesn't conform to its schema.
+// On receive, Message holds the received message, which is still locked
+// and has to be settled by the caller.
+type SchemaError struct {
+	Message *Message
+	Err     error
+}
+
+func (e SchemaError) Error() string {
+	return "Message body doesn't conform to schema " + e.Message.SchemaId() + ": " + e.Err.Error()
+}
+
+// Attaches the schema id of the body to the message.
+func (m *Message) SetSchemaId(id string) {
+	m.SetProperty(SchemaIdProperty, id)
+}
+
+// Returns the schema id of the body, or "" if the message has none.
+func (m *Message) SchemaId() string {
+	return m.GetProperty(SchemaIdProperty)
+}
+
+// Validates the body of a message carrying a schema id against the client's schema registry.
+func (q *QueueClient) validateSchema(msg *Message) error {
+
+	id := msg.SchemaId()
+	if q.SchemaRegistry == nil || id == "" {
+		return nil
+	}
+
+	return q.withholdBody(q.SchemaRegistry.Validate(id, msg.Body))
+}
@@ -0,0 +1,92 @@
+package queue
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type testSchemaRegistry map[string]func(body []byte) error
+
+func (r testSchemaRegistry) Validate(schemaId string, body []byte) error {
+	validate, ok := r[schemaId]
+	if !ok {
+		return errors.New("unknown schema")
+	}
+	return validate(body)
+}
+
+var schemas = testSchemaRegistry{
+	"order-v1": func(body []byte) error {
+		if len(body) == 0 {
+			return errors.New("empty body")
+		}
+		return nil
+	},
+}
+
+func Test_SchemaRegistry_send(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	sent := 0
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		sent++
+		return respondWith(201, "")(req)
+	}))
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test", SchemaRegistry: schemas}
+
+	tests := []struct {
+		schemaId string
+		body     string
+		valid    bool
+	}{
+		{"", "", true},
+		{"order-v1", "{}", true},
+		{"order-v1", "", false},
+		{"order-v2", "{}", false},
+	}
+
+	for _, test := range tests {
+		msg := NewMessage([]byte(test.body))
+		if test.schemaId != "" {
+			msg.SetSchemaId(test.schemaId)
+		}
+
+		before := sent
+		err := cli.SendMessage(msg)
+
+		if _, ok := err.(SchemaError); ok == test.valid {
+			t.Fatalf("Expected valid %v for schema %s and body %q but got %v", test.valid, test.schemaId, test.body, err)
+		}
+
+		if (sent > before) != test.valid {
+			t.Fatalf("Expected only valid messages to be sent")
+		}
+	}
+}
+
+func Test_SchemaRegistry_receive(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		resp, _ := respondWith(200, "")(req)
+		resp.Header.Set(SchemaIdProperty, `"order-v1"`)
+		return resp, nil
+	}))
+
+	cli := &QueueClient{Namespace: "test", QueueName: "test", SchemaRegistry: schemas}
+
+	_, err := cli.GetMessage()
+
+	schemaErr, ok := err.(SchemaError)
+	if !ok {
+		t.Fatalf("Expected error type SchemaError but got %v", err)
+	}
+
+	if schemaErr.Message == nil || schemaErr.Message.SchemaId() != "order-v1" {
+		t.Fatal("Expected SchemaError to carry the received message")
+	}
+}
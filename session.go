@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"hash/fnv"
+)
+
+// Namespace of the name-based session ids returned by AggregateSessionId.
+var sessionNamespace = [16]byte{0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+
+// Returns a new random (version 4) UUID to be used as a session id.
+func NewSessionId() (string, error) {
+	return newUuid()
+}
+
+func newUuid() (string, error) {
+
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		return "", wrap(err, "UUID generation failed")
+	}
+
+	u[6] = (u[6] & 0x0f) | 0x40 // version 4
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return formatUuid(u), nil
+}
+
+// Returns a name-based (version 5) UUID derived from the aggregate id, so that
+// all messages of one aggregate, e.g. "order/42", always share one session.
+func AggregateSessionId(aggregate string) string {
+
+	h := sha1.New()
+	h.Write(sessionNamespace[:])
+	h.Write([]byte(aggregate))
+
+	var u [16]byte
+	copy(u[:], h.Sum(nil))
+
+	u[6] = (u[6] & 0x0f) | 0x50 // version 5
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant
+
+	return formatUuid(u)
+}
+
+// Returns one of a fixed number of session ids for the business key, e.g. "bucket-3".
+// The same key always maps to the same bucket, which bounds the number of sessions
+// while keeping related messages ordered.
+func BucketSessionId(key string, buckets int) string {
+
+	if buckets < 1 {
+		buckets = 1
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	return fmt.Sprintf("bucket-%d", h.Sum32()%uint32(buckets))
+}
+
+func formatUuid(u [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"regexp"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-([0-9a-f])[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func Test_NewSessionId(t *testing.T) {
+
+	id1, err := NewSessionId()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id2, err := NewSessionId()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m := uuidPattern.FindStringSubmatch(id1); m == nil || m[1] != "4" {
+		t.Fatalf("Expected version 4 UUID but got %s", id1)
+	}
+
+	if id1 == id2 {
+		t.Fatalf("Expected unique session ids but got %s twice", id1)
+	}
+}
+
+func Test_AggregateSessionId(t *testing.T) {
+
+	id := AggregateSessionId("order/42")
+
+	if m := uuidPattern.FindStringSubmatch(id); m == nil || m[1] != "5" {
+		t.Fatalf("Expected version 5 UUID but got %s", id)
+	}
+
+	if AggregateSessionId("order/42") != id {
+		t.Fatal("Expected the same session id for the same aggregate")
+	}
+
+	if AggregateSessionId("order/43") == id {
+		t.Fatal("Expected different session ids for different aggregates")
+	}
+}
+
+func Test_BucketSessionId(t *testing.T) {
+
+	tests := []struct {
+		key      string
+		buckets  int
+		expected string
+	}{
+		{"customer-1", 16, "bucket-7"},
+		{"customer-1", 1, "bucket-0"},
+		{"customer-1", 0, "bucket-0"},
+	}
+
+	for _, test := range tests {
+		if id := BucketSessionId(test.key, test.buckets); id != test.expected {
+			t.Fatalf("Expected session id %s but got %s", test.expected, id)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		seen[BucketSessionId(string(rune('a'+i%26))+string(rune(i)), 8)] = true
+	}
+
+	if len(seen) != 8 {
+		t.Fatalf("Expected keys to spread over 8 buckets but got %v", len(seen))
+	}
+}
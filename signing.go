@@ -0,0 +1,179 @@
+package queue
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Custom properties carrying the signature of a message.
+const (
+	SignatureProperty           = "Signature"
+	SignatureKeyIdProperty      = "Signature-Key-Id"
+	SignatureAlgorithmProperty  = "Signature-Algorithm"
+	SignaturePropertiesProperty = "Signature-Properties"
+)
+
+// Signature algorithms.
+const (
+	SignatureHS256   = "HS256"
+	SignatureEd25519 = "Ed25519"
+)
+
+// Returned by VerifyMessage if a message is unsigned or its signature doesn't match.
+var ErrInvalidSignature = errors.New("Invalid message signature")
+
+// Signs the body and selected properties of messages, so consumers can detect
+// tampering or misrouted producers with VerifyMessage.
+type MessageSigner struct {
+	// Id under which consumers look up the key to verify signatures.
+	KeyId string
+
+	// []byte secret for HMAC-SHA256 or ed25519.PrivateKey.
+	Key interface{}
+
+	// Names of the custom properties covered by the signature besides the body.
+	Properties []string
+}
+
+// Resolves the keys verifying message signatures.
+type SignatureKeyResolver interface {
+	// Returns the []byte secret for HMAC-SHA256 or the ed25519.PublicKey of the key id.
+	ResolveKey(keyId string) (interface{}, error)
+}
+
+// Returned when a received message isn't signed or its signature doesn't match.
+// Message holds the received message, which is still locked
+// and has to be settled by the caller.
+type SignatureError struct {
+	Message *Message
+	Err     error
+}
+
+func (e SignatureError) Error() string {
+	return "Message " + e.Message.Id + " failed signature verification: " + e.Err.Error()
+}
+
+// Signs the message, setting the signature properties.
+func (s *MessageSigner) Sign(msg *Message) error {
+
+	var algorithm string
+	switch s.Key.(type) {
+	case []byte:
+		algorithm = SignatureHS256
+	case ed25519.PrivateKey:
+		algorithm = SignatureEd25519
+	default:
+		return fmt.Errorf("Unsupported signing key type %T", s.Key)
+	}
+
+	msg.SetProperty(SignatureKeyIdProperty, s.KeyId)
+	msg.SetProperty(SignatureAlgorithmProperty, algorithm)
+	msg.SetProperty(SignaturePropertiesProperty, strings.Join(s.Properties, ","))
+
+	data := signedData(msg)
+
+	var sig []byte
+	switch key := s.Key.(type) {
+	case []byte:
+		h := hmac.New(sha256.New, key)
+		h.Write(data)
+		sig = h.Sum(nil)
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(key, data)
+	}
+
+	msg.SetProperty(SignatureProperty, base64.StdEncoding.EncodeToString(sig))
+	return nil
+}
+
+// Verifies the signature of the message with the key resolved for its key id.
+// Returns ErrInvalidSignature if the message isn't signed or the signature doesn't match.
+func VerifyMessage(msg *Message, keys SignatureKeyResolver) error {
+
+	encoded := msg.GetProperty(SignatureProperty)
+	if encoded == "" {
+		return ErrInvalidSignature
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	key, err := keys.ResolveKey(msg.GetProperty(SignatureKeyIdProperty))
+	if err != nil {
+		return wrap(err, "Resolving signature key failed")
+	}
+
+	data := signedData(msg)
+	algorithm := msg.GetProperty(SignatureAlgorithmProperty)
+
+	valid := false
+	switch key := key.(type) {
+	case []byte:
+		h := hmac.New(sha256.New, key)
+		h.Write(data)
+		valid = algorithm == SignatureHS256 && hmac.Equal(sig, h.Sum(nil))
+	case ed25519.PublicKey:
+		valid = algorithm == SignatureEd25519 && ed25519.Verify(key, data, sig)
+	default:
+		return fmt.Errorf("Unsupported verification key type %T", key)
+	}
+
+	if !valid {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// Returns the data covered by the signature: key id, algorithm, the names and values
+// of the signed properties and the body, separated by newlines.
+func signedData(msg *Message) []byte {
+
+	var b bytes.Buffer
+
+	names := msg.GetProperty(SignaturePropertiesProperty)
+	b.WriteString(msg.GetProperty(SignatureKeyIdProperty) + "\n")
+	b.WriteString(msg.GetProperty(SignatureAlgorithmProperty) + "\n")
+	b.WriteString(names + "\n")
+
+	if names != "" {
+		for _, name := range strings.Split(names, ",") {
+			b.WriteString(strings.ToLower(name) + ":" + msg.GetProperty(name) + "\n")
+		}
+	}
+
+	b.Write(msg.Body)
+	return b.Bytes()
+}
+
+// Signs a copy of the message if the client has a Signer.
+func (q *QueueClient) sign(msg *Message) (*Message, error) {
+
+	if q.Signer == nil {
+		return msg, nil
+	}
+
+	signed := msg.Clone()
+	if err := q.Signer.Sign(signed); err != nil {
+		return nil, err
+	}
+	return signed, nil
+}
+
+// Verifies the signature of a received message if the client has SignatureKeys.
+func (q *QueueClient) verify(msg *Message) error {
+
+	if q.SignatureKeys == nil {
+		return nil
+	}
+
+	return VerifyMessage(msg, q.SignatureKeys)
+}
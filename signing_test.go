@@ -0,0 +1,106 @@
+package queue
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type staticKeys map[string]interface{}
+
+func (k staticKeys) ResolveKey(keyId string) (interface{}, error) {
+	if key, ok := k[keyId]; ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown key " + keyId)
+}
+
+func Test_MessageSigner(t *testing.T) {
+
+	public, private, _ := ed25519.GenerateKey(nil)
+
+	tests := []struct {
+		signer *MessageSigner
+		keys   staticKeys
+	}{
+		{&MessageSigner{KeyId: "hmac", Key: []byte("secret"), Properties: []string{"Tenant"}}, staticKeys{"hmac": []byte("secret")}},
+		{&MessageSigner{KeyId: "ed", Key: private, Properties: []string{"Tenant"}}, staticKeys{"ed": public}},
+	}
+
+	for _, test := range tests {
+		msg := NewMessage([]byte("hello"))
+		msg.SetProperty("Tenant", "contoso")
+
+		if err := test.signer.Sign(msg); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := VerifyMessage(msg, test.keys); err != nil {
+			t.Fatalf("Expected valid signature but got %v", err)
+		}
+
+		tampered := msg.Clone()
+		tampered.Body = []byte("hello!")
+		if err := VerifyMessage(tampered, test.keys); err != ErrInvalidSignature {
+			t.Fatalf("Expected tampered body to be detected but got %v", err)
+		}
+
+		tampered = msg.Clone()
+		tampered.SetProperty("Tenant", "fabrikam")
+		if err := VerifyMessage(tampered, test.keys); err != ErrInvalidSignature {
+			t.Fatalf("Expected tampered property to be detected but got %v", err)
+		}
+	}
+
+	if err := VerifyMessage(NewMessage([]byte("hello")), staticKeys{}); err != ErrInvalidSignature {
+		t.Fatalf("Expected unsigned message to be rejected but got %v", err)
+	}
+}
+
+func Test_signedRoundTrip(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	var sent *http.Request
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		if req.Method == "POST" && sent == nil {
+			sent = req
+			return respondWith(201, "")(req)
+		}
+
+		// echo the sent message back
+		resp, _ := respondWith(200, "hello")(req)
+		for k, v := range sent.Header {
+			resp.Header[k] = v
+		}
+		return resp, nil
+	}))
+
+	cli := &QueueClient{
+		Namespace:     "test",
+		QueueName:     "test",
+		Signer:        &MessageSigner{KeyId: "k1", Key: []byte("secret")},
+		SignatureKeys: staticKeys{"k1": []byte("secret")},
+	}
+
+	msg := NewMessage([]byte("hello"))
+	if err := cli.SendMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.GetProperty(SignatureProperty) != "" {
+		t.Fatalf("Expected the sent message not to be modified")
+	}
+
+	if _, err := cli.GetMessage(); err != nil {
+		t.Fatalf("Expected received message to verify but got %v", err)
+	}
+
+	cli.SignatureKeys = staticKeys{"k1": []byte("other")}
+
+	_, err := cli.GetMessage()
+	if _, ok := err.(SignatureError); !ok {
+		t.Fatalf("Expected error type SignatureError but got %v", err)
+	}
+}
@@ -0,0 +1,347 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Ordered persistent store for messages which couldn't be sent.
+type Spool interface {
+	// Appends the message to the end of the spool.
+	Append(msg *Message) error
+
+	// Returns the oldest message or nil if the spool is empty.
+	Peek() (*Message, error)
+
+	// Removes the oldest message.
+	Remove() error
+}
+
+// Spool keeping messages in an append-only file of JSON lines. The position of the
+// oldest message is kept in a file with the ".offset" suffix next to it.
+// Both files are truncated once all messages are removed.
+//
+// Thread-safe. Create with NewFileSpool.
+type FileSpool struct {
+	path   string
+	mu     sync.Mutex
+	offset int64
+	next   int64 // length of the oldest record once read by Peek, zero otherwise
+}
+
+var _ Spool = (*FileSpool)(nil)
+
+// Opens the spool at path, creating it if needed. A record partially
+// written during a crash is discarded.
+func NewFileSpool(path string) (*FileSpool, error) {
+
+	s := &FileSpool{path: path}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, wrap(err, "Error reading spool")
+	}
+
+	if end := int64(bytes.LastIndexByte(data, '\n') + 1); end < int64(len(data)) {
+		if err := os.Truncate(path, end); err != nil {
+			return nil, wrap(err, "Error truncating spool")
+		}
+	}
+
+	offset, err := ioutil.ReadFile(s.offsetPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, wrap(err, "Error reading spool offset")
+	}
+
+	if len(offset) > 0 {
+		s.offset, err = strconv.ParseInt(string(offset), 10, 64)
+		if err != nil {
+			return nil, wrap(err, "Invalid spool offset")
+		}
+	}
+
+	// an offset past the end is left by a crash of an older version between
+	// truncating the spool and resetting the offset
+	if s.offset > int64(len(data)) {
+		s.offset = 0
+		if err := s.writeOffset(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *FileSpool) Append(msg *Message) error {
+
+	record, err := json.Marshal(msg)
+	if err != nil {
+		return wrap(err, "Error encoding message")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return wrap(err, "Error opening spool")
+	}
+
+	if _, err := f.Write(append(record, '\n')); err != nil {
+		f.Close()
+		return wrap(err, "Error writing spool")
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return wrap(err, "Error syncing spool")
+	}
+
+	return f.Close()
+}
+
+func (s *FileSpool) Peek() (*Message, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, wrap(err, "Error opening spool")
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(s.offset, io.SeekStart); err != nil {
+		return nil, wrap(err, "Error seeking spool")
+	}
+
+	record, err := bufio.NewReader(f).ReadBytes('\n')
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, wrap(err, "Error reading spool")
+	}
+
+	msg := &Message{}
+	if err := json.Unmarshal(record, msg); err != nil {
+		return nil, fmt.Errorf("Corrupted spool record at offset %v: %v", s.offset, err)
+	}
+
+	s.next = int64(len(record))
+	return msg, nil
+}
+
+func (s *FileSpool) Remove() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next == 0 {
+		return fmt.Errorf("Remove called without Peek")
+	}
+
+	s.offset += s.next
+	s.next = 0
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return wrap(err, "Error reading spool")
+	}
+
+	if s.offset < info.Size() {
+		return s.writeOffset()
+	}
+
+	// reset the offset before truncating: a crash in between replays the removed
+	// messages again rather than losing the ones appended later
+	s.offset = 0
+	if err := s.writeOffset(); err != nil {
+		return err
+	}
+
+	if err := os.Truncate(s.path, 0); err != nil {
+		return wrap(err, "Error truncating spool")
+	}
+
+	return nil
+}
+
+// Replaces the offset file atomically.
+func (s *FileSpool) writeOffset() error {
+
+	tmp := s.offsetPath() + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.FormatInt(s.offset, 10)), 0600); err != nil {
+		return wrap(err, "Error writing spool offset")
+	}
+
+	if err := os.Rename(tmp, s.offsetPath()); err != nil {
+		return wrap(err, "Error writing spool offset")
+	}
+
+	return nil
+}
+
+func (s *FileSpool) offsetPath() string {
+	return s.path + ".offset"
+}
+
+// Sends messages through Sender and spools them when Service Bus can't be reached
+// (network failures, timeouts and internal errors). Spooled messages are replayed in
+// order every RetryInterval; new messages are spooled behind them until the spool is
+// empty, so ordering is preserved. A message is removed from the spool only after it
+// has been sent, so delivery is at-least-once.
+//
+// Thread-safe. Create with NewSpoolingSender and Close when done.
+type SpoolingSender struct {
+	Sender Sender
+	Spool  Spool
+
+	// How often spooled messages are replayed. Defaults to DefaultSpoolRetryInterval.
+	RetryInterval time.Duration
+
+	mu      sync.Mutex
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+var _ Sender = (*SpoolingSender)(nil)
+
+// How often SpoolingSender replays spooled messages by default.
+const DefaultSpoolRetryInterval = 10 * time.Second
+
+// Creates a sender and starts replaying the spool in the background.
+// A retryInterval of zero uses DefaultSpoolRetryInterval.
+func NewSpoolingSender(sender Sender, spool Spool, retryInterval time.Duration) *SpoolingSender {
+
+	if retryInterval <= 0 {
+		retryInterval = DefaultSpoolRetryInterval
+	}
+
+	s := &SpoolingSender{
+		Sender:        sender,
+		Spool:         spool,
+		RetryInterval: retryInterval,
+		stop:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+
+	go s.run()
+	return s
+}
+
+// Sends the message or spools it if Service Bus can't be reached. Errors which
+// retrying can't fix (e.g. BadRequestError) are returned and the message is not spooled.
+func (s *SpoolingSender) SendMessage(msg *Message) error {
+
+	s.mu.Lock()
+	pending, err := s.Spool.Peek()
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if pending == nil {
+		err := s.Sender.SendMessage(msg)
+		if err == nil || !isTransient(err) {
+			return err
+		}
+		logger.Debug("Spooling message: " + err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Spool.Append(msg)
+}
+
+// Sends spooled messages in order until the spool is empty or sending fails.
+// Messages failing with a non-transient error are dropped and logged.
+func (s *SpoolingSender) Replay() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for {
+		msg, err := s.Spool.Peek()
+		if err != nil || msg == nil {
+			return err
+		}
+
+		if err := s.Sender.SendMessage(msg); err != nil {
+			if isTransient(err) {
+				return err
+			}
+			logger.Error("Dropping spooled message "+msg.Id, err)
+		}
+
+		if err := s.Spool.Remove(); err != nil {
+			return err
+		}
+	}
+}
+
+// Stops replaying the spool in the background. Spooled messages are kept.
+func (s *SpoolingSender) Close() {
+
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.stopped
+}
+
+func (s *SpoolingSender) run() {
+
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(s.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.Replay(); err != nil {
+				logger.Debug("Replaying spool failed: " + err.Error())
+			}
+		}
+	}
+}
+
+// Reports whether sending may succeed when retried later: network failures, timeouts
+// and 5xx responses. Other errors, e.g. encoding or validation failures, would fail
+// again on every replay and block the messages spooled behind them.
+func isTransient(err error) bool {
+
+	var netErr net.Error
+	var unknown UnknownStatusError
+
+	switch {
+	case errors.As(err, &netErr), errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &RequestTimeoutError{}), errors.As(err, &InternalError{}):
+		return true
+	case errors.As(err, &unknown):
+		return unknown.Code >= 500
+	}
+
+	return false
+}
@@ -0,0 +1,236 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestSpool(t *testing.T) (*FileSpool, string) {
+
+	dir, err := ioutil.TempDir("", "spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "messages")
+	s, err := NewFileSpool(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, dir
+}
+
+func Test_FileSpool(t *testing.T) {
+
+	s, dir := newTestSpool(t)
+	defer os.RemoveAll(dir)
+
+	for _, label := range []string{"first", "second"} {
+		msg := NewMessage([]byte("hello"))
+		msg.Label = label
+		msg.Properties.Set("Key", "value")
+		if err := s.Append(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msg, err := s.Peek()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Label != "first" || string(msg.Body) != "hello" || msg.Properties.Get("Key") != "value" {
+		t.Fatalf("Expected first message but got %+v", msg)
+	}
+
+	if err := s.Remove(); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopening continues after the removed message
+	s, err = NewFileSpool(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, _ = s.Peek()
+	if msg == nil || msg.Label != "second" {
+		t.Fatalf("Expected second message but got %+v", msg)
+	}
+
+	if err := s.Remove(); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg, _ := s.Peek(); msg != nil {
+		t.Fatalf("Expected empty spool but got %+v", msg)
+	}
+
+	if info, _ := os.Stat(s.path); info.Size() != 0 {
+		t.Fatalf("Expected spool to be truncated but got size %v", info.Size())
+	}
+}
+
+func Test_FileSpool_partialRecord(t *testing.T) {
+
+	s, dir := newTestSpool(t)
+	defer os.RemoveAll(dir)
+
+	s.Append(NewMessage([]byte("hello")))
+
+	f, _ := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0600)
+	f.Write([]byte(`{"Body":`))
+	f.Close()
+
+	s, err := NewFileSpool(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Append(NewMessage([]byte("world")))
+
+	for _, expected := range []string{"hello", "world"} {
+		msg, err := s.Peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg == nil || string(msg.Body) != expected {
+			t.Fatalf("Expected message %v but got %+v", expected, msg)
+		}
+		s.Remove()
+	}
+}
+
+func Test_FileSpool_crashBeforeOffsetReset(t *testing.T) {
+
+	s, dir := newTestSpool(t)
+	defer os.RemoveAll(dir)
+
+	// the spool was truncated but the crash left the offset of the removed messages
+	s.Append(NewMessage([]byte("sent")))
+	os.Truncate(s.path, 0)
+	ioutil.WriteFile(s.offsetPath(), []byte("100"), 0600)
+
+	s, err := NewFileSpool(s.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		s.Append(NewMessage([]byte("after crash")))
+	}
+
+	for i := 0; i < 10; i++ {
+		msg, err := s.Peek()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg == nil || string(msg.Body) != "after crash" {
+			t.Fatalf("Expected message appended after the crash but got %+v", msg)
+		}
+		s.Remove()
+	}
+
+	if offset, _ := ioutil.ReadFile(s.offsetPath()); string(offset) != "0" {
+		t.Fatalf("Expected offset reset once the spool is empty but got %s", offset)
+	}
+}
+
+func Test_SpoolingSender(t *testing.T) {
+
+	spool, dir := newTestSpool(t)
+	defer os.RemoveAll(dir)
+
+	target := &recordingSender{err: InternalError{Code: 500}}
+	s := NewSpoolingSender(target, spool, time.Hour)
+	defer s.Close()
+
+	for _, label := range []string{"first", "second"} {
+		msg := NewMessage([]byte("hello"))
+		msg.Label = label
+		if err := s.SendMessage(msg); err != nil {
+			t.Fatalf("Expected message to be spooled but got %v", err)
+		}
+	}
+
+	// the second message is spooled behind the first without being sent
+	if len(target.sent) != 1 {
+		t.Fatalf("Expected 1 send attempt but got %v", len(target.sent))
+	}
+
+	target.err = nil
+	target.sent = nil
+
+	if err := s.Replay(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(target.sent) != 2 || target.sent[0].Label != "first" || target.sent[1].Label != "second" {
+		t.Fatalf("Expected spooled messages to be replayed in order but got %+v", target.sent)
+	}
+
+	if msg, _ := spool.Peek(); msg != nil {
+		t.Fatalf("Expected empty spool but got %+v", msg)
+	}
+}
+
+func Test_SpoolingSender_permanentError(t *testing.T) {
+
+	spool, dir := newTestSpool(t)
+	defer os.RemoveAll(dir)
+
+	s := NewSpoolingSender(&recordingSender{err: BadRequestError{Code: 400}}, spool, time.Hour)
+	defer s.Close()
+
+	if err := s.SendMessage(NewMessage([]byte("hello"))); err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+
+	if msg, _ := spool.Peek(); msg != nil {
+		t.Fatalf("Expected message not to be spooled but got %+v", msg)
+	}
+}
+
+func Test_SpoolingSender_defaultRetryInterval(t *testing.T) {
+
+	spool, dir := newTestSpool(t)
+	defer os.RemoveAll(dir)
+
+	s := NewSpoolingSender(&recordingSender{}, spool, 0)
+	defer s.Close()
+
+	if s.RetryInterval != DefaultSpoolRetryInterval {
+		t.Fatalf("Expected default retry interval but got %v", s.RetryInterval)
+	}
+}
+
+func Test_isTransient(t *testing.T) {
+
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	cases := map[error]bool{
+		wrap(refused, "Sending POST createRequest failed"):    true,
+		context.DeadlineExceeded:                              true,
+		InternalError{Code: 500}:                              true,
+		RequestTimeoutError{Code: 408}:                        true,
+		UnknownStatusError{Code: 503}:                         true,
+		UnknownStatusError{Code: 429}:                         false,
+		BadRequestError{Code: 400}:                            false,
+		NotAuthorizedError{Code: 401}:                         false,
+		ErrClientClosed:                                       false,
+		errors.New("FIPS 140 mode is required"):               false,
+		SchemaError{Message: &Message{}, Err: ErrInvalidJose}: false,
+	}
+
+	for err, expected := range cases {
+		if isTransient(err) != expected {
+			t.Fatalf("Expected isTransient(%v) to be %v", err, expected)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package queue
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Address StatsD agents listen on by default.
+const DefaultStatsdAddr = "localhost:8125"
+
+// MetricsSink sending counters over UDP to a StatsD or DogStatsD agent,
+// e.g. Datadog or Telegraf. Sending failures are ignored, as is usual for StatsD.
+//
+// Thread-safe. Create with NewStatsdSink, or use the zero value, which
+// connects to DefaultStatsdAddr on the first count.
+type StatsdSink struct {
+	// Address of the agent, DefaultStatsdAddr if empty.
+	Addr string
+
+	// Prefix of metric names e.g. myapp.servicebus
+	Prefix string
+
+	// Send tags in the DogStatsD format (name:1|c|#queue:orders). Plain StatsD has no
+	// tags, so otherwise their values are appended to the name (name.orders).
+	DogStatsD bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+var _ MetricsSink = (*StatsdSink)(nil)
+
+// Creates a sink sending to the agent at addr e.g. localhost:8125
+func NewStatsdSink(addr string, prefix string) (*StatsdSink, error) {
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, wrap(err, "Connecting to StatsD agent failed")
+	}
+
+	return &StatsdSink{Addr: addr, Prefix: prefix, conn: conn}, nil
+}
+
+func (s *StatsdSink) Count(name string, delta int64, tags Tags) {
+
+	line := s.format(name, delta, tags)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		addr := s.Addr
+		if addr == "" {
+			addr = DefaultStatsdAddr
+		}
+
+		// counts are dropped until the address resolves
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			return
+		}
+		s.conn = conn
+	}
+
+	s.conn.Write([]byte(line))
+}
+
+// Closes the connection to the agent.
+func (s *StatsdSink) Close() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *StatsdSink) format(name string, delta int64, tags Tags) string {
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if s.Prefix != "" {
+		name = s.Prefix + "." + name
+	}
+
+	value := ":" + strconv.FormatInt(delta, 10) + "|c"
+
+	if s.DogStatsD {
+		pairs := make([]string, len(keys))
+		for i, k := range keys {
+			pairs[i] = k + ":" + tags[k]
+		}
+		if len(pairs) == 0 {
+			return name + value
+		}
+		return name + value + "|#" + strings.Join(pairs, ",")
+	}
+
+	for _, k := range keys {
+		name += "." + strings.Replace(tags[k], ".", "_", -1)
+	}
+	return name + value
+}
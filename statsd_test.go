@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_StatsdSink_format(t *testing.T) {
+
+	tags := Tags{TagOperation: "send", TagQueue: "orders.eu", TagStatusClass: "2xx"}
+
+	s := &StatsdSink{Prefix: "myapp"}
+	if line := s.format(MetricRequests, 1, tags); line != "myapp.requests.send.orders_eu.2xx:1|c" {
+		t.Fatalf("Unexpected StatsD line %s", line)
+	}
+
+	s.DogStatsD = true
+	if line := s.format(MetricRequests, 1, tags); line != "myapp.requests:1|c|#operation:send,queue:orders.eu,status_class:2xx" {
+		t.Fatalf("Unexpected DogStatsD line %s", line)
+	}
+}
+
+func Test_StatsdSink(t *testing.T) {
+
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Close()
+
+	s, err := NewStatsdSink(agent.LocalAddr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Count(MetricRequests, 2, Tags{TagQueue: "orders"})
+
+	buf := make([]byte, 512)
+	agent.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := agent.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if line := string(buf[:n]); line != "requests.orders:2|c" {
+		t.Fatalf("Expected requests.orders:2|c but got %s", line)
+	}
+}
+
+func Test_StatsdSink_zeroValue(t *testing.T) {
+
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer agent.Close()
+
+	s := &StatsdSink{Addr: agent.LocalAddr().String()}
+	defer s.Close()
+
+	s.Count(MetricRequests, 1, nil)
+
+	buf := make([]byte, 512)
+	agent.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := agent.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if line := string(buf[:n]); line != "requests:1|c" {
+		t.Fatalf("Expected requests:1|c but got %s", line)
+	}
+
+	if err := (&StatsdSink{}).Close(); err != nil {
+		t.Fatalf("Expected closing an unused sink to succeed but got %v", err)
+	}
+}
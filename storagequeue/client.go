@@ -0,0 +1,353 @@
+// Package storagequeue implements the queue.Sender and queue.Receiver interfaces
+// on top of Azure Storage Queues, so applications can switch between
+// Service Bus and Storage Queues behind one API.
+//
+// Storage Queues carry only the message body; custom properties and most broker
+// properties of queue.Message are not supported and are ignored on send.
+package storagequeue
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	queue "github.com/g-rad/go-azurequeue"
+)
+
+const storageVersion = "2017-11-09"
+
+const azureStorageQueueURL = "https://%s.queue.core.windows.net/%s/"
+
+var httpClientOverride queue.HttpClient = nil
+
+// Sets the package's http client.
+func SetHttpClient(client queue.HttpClient) {
+	httpClientOverride = client
+}
+
+// Thread-safe client for Azure Storage Queue.
+type QueueClient struct {
+	// Storage account name.
+	AccountName string
+
+	// Base64 encoded storage account access key.
+	AccountKey string
+
+	// Name of the queue.
+	QueueName string
+
+	// How long in seconds a received message stays invisible to other receivers.
+	// Zero means the service default of 30 seconds.
+	VisibilityTimeout int
+
+	mu         sync.Mutex
+	httpClient queue.HttpClient
+}
+
+var _ queue.Sender = (*QueueClient)(nil)
+var _ queue.Receiver = (*QueueClient)(nil)
+
+// Retrieves the next message from the queue and makes it invisible to other receivers
+// for the visibility timeout. Returns queue.NoMessagesAvailableError if the queue is empty.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/storageservices/get-messages
+func (q *QueueClient) GetMessage() (*queue.Message, error) {
+
+	query := url.Values{}
+	query.Set("numofmessages", "1")
+	if q.VisibilityTimeout > 0 {
+		query.Set("visibilitytimeout", strconv.Itoa(q.VisibilityTimeout))
+	}
+
+	body, err := q.do("GET", "messages", query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	list := messageList{}
+	if err := xml.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("Error parsing messages: %v", err)
+	}
+
+	if len(list.Messages) == 0 {
+		return nil, queue.NoMessagesAvailableError{Code: http.StatusOK, Body: string(body)}
+	}
+
+	return list.Messages[0].toMessage()
+}
+
+// Sends message to a Storage queue. Message.TimeToLive and Message.ScheduledEnqueueTimeUtc
+// are honoured; the latter is translated into an initial visibility timeout.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/storageservices/put-message
+func (q *QueueClient) SendMessage(msg *queue.Message) error {
+
+	query := url.Values{}
+	if msg.TimeToLive > 0 {
+		query.Set("messagettl", strconv.Itoa(msg.TimeToLive))
+	}
+	if !msg.ScheduledEnqueueTimeUtc.IsZero() {
+		if delay := int(time.Until(msg.ScheduledEnqueueTimeUtc) / time.Second); delay > 0 {
+			query.Set("visibilitytimeout", strconv.Itoa(delay))
+		}
+	}
+
+	body, err := xml.Marshal(putMessage{Text: base64.StdEncoding.EncodeToString(msg.Body)})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.do("POST", "messages", query, body)
+	return err
+}
+
+// Makes a message visible to other receivers immediately.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/storageservices/update-message
+func (q *QueueClient) UnlockMessage(msg *queue.Message) error {
+
+	query := url.Values{}
+	query.Set("popreceipt", msg.LockToken)
+	query.Set("visibilitytimeout", "0")
+
+	_, err := q.do("PUT", "messages/"+msg.Id, query, nil)
+	return err
+}
+
+// Deletes a previously received message from the queue.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/storageservices/delete-message2
+func (q *QueueClient) DeleteMessage(msg *queue.Message) error {
+
+	query := url.Values{}
+	query.Set("popreceipt", msg.LockToken)
+
+	_, err := q.do("DELETE", "messages/"+msg.Id, query, nil)
+	return err
+}
+
+func (q *QueueClient) do(method string, path string, query url.Values, body []byte) ([]byte, error) {
+
+	req, err := q.createRequest(method, path, query, body, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("Request create failed: %v", err)
+	}
+
+	resp, err := q.getClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Sending %s request failed: %v", method, err)
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading response body: %v", err)
+	}
+
+	if err := handleStatusCode(resp.StatusCode, respBody); err != nil {
+		return nil, err
+	}
+
+	return respBody, nil
+}
+
+func (q *QueueClient) createRequest(method string, path string, query url.Values, body []byte, date time.Time) (*http.Request, error) {
+
+	u := fmt.Sprintf(azureStorageQueueURL, q.AccountName, q.QueueName) + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("x-ms-date", date.UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", storageVersion)
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+
+	sig, err := q.makeSignature(req)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "SharedKey "+q.AccountName+":"+sig)
+	return req, nil
+}
+
+func (q *QueueClient) getClient() queue.HttpClient {
+
+	if httpClientOverride != nil {
+		return httpClientOverride
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.httpClient == nil {
+		q.httpClient = &http.Client{}
+	}
+
+	return q.httpClient
+}
+
+// Signs the request with the account key.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/storageservices/authorize-with-shared-key
+func (q *QueueClient) makeSignature(req *http.Request) (string, error) {
+
+	key, err := base64.StdEncoding.DecodeString(q.AccountKey)
+	if err != nil {
+		return "", fmt.Errorf("Invalid account key: %v", err)
+	}
+
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(q.stringToSign(req)))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+func (q *QueueClient) stringToSign(req *http.Request) string {
+
+	contentLength := ""
+	if req.ContentLength > 0 {
+		contentLength = strconv.FormatInt(req.ContentLength, 10)
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		contentLength,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, x-ms-date is used instead
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+		canonicalizedHeaders(req.Header) + q.canonicalizedResource(req.URL),
+	}, "\n")
+}
+
+func canonicalizedHeaders(header http.Header) string {
+
+	var names []string
+	for k := range header {
+		if name := strings.ToLower(k); strings.HasPrefix(name, "x-ms-") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + strings.TrimSpace(header.Get(name)) + "\n")
+	}
+	return b.String()
+}
+
+func (q *QueueClient) canonicalizedResource(u *url.URL) string {
+
+	resource := "/" + q.AccountName + u.EscapedPath()
+
+	query := u.Query()
+	var names []string
+	for k := range query {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		values := query[name]
+		sort.Strings(values)
+		resource += "\n" + strings.ToLower(name) + ":" + strings.Join(values, ",")
+	}
+	return resource
+}
+
+func handleStatusCode(code int, body []byte) error {
+
+	if code >= 200 && code < 300 {
+		return nil
+	}
+
+	switch code {
+	case 400:
+		return queue.BadRequestError{Code: code, Body: string(body)}
+	case 401:
+		return queue.NotAuthorizedError{Code: code, Body: string(body)}
+	case 403:
+		return queue.ForbiddenError{Code: code, Body: string(body)}
+	case 404:
+		if bytes.Contains(body, []byte("QueueNotFound")) {
+			return queue.QueueDontExistError{Code: code, Body: string(body)}
+		}
+		return queue.MessageDontExistError{Code: code, Body: string(body)}
+	case 500:
+		return queue.InternalError{Code: code, Body: string(body)}
+	}
+
+	return queue.UnknownStatusError{Code: code, Body: string(body)}
+}
+
+type putMessage struct {
+	XMLName xml.Name `xml:"QueueMessage"`
+	Text    string   `xml:"MessageText"`
+}
+
+type messageList struct {
+	Messages []storageMessage `xml:"QueueMessage"`
+}
+
+type storageMessage struct {
+	MessageId       string `xml:"MessageId"`
+	InsertionTime   string `xml:"InsertionTime"`
+	ExpirationTime  string `xml:"ExpirationTime"`
+	PopReceipt      string `xml:"PopReceipt"`
+	TimeNextVisible string `xml:"TimeNextVisible"`
+	DequeueCount    int    `xml:"DequeueCount"`
+	MessageText     string `xml:"MessageText"`
+}
+
+func (m storageMessage) toMessage() (*queue.Message, error) {
+
+	body, err := base64.StdEncoding.DecodeString(m.MessageText)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding message body: %v", err)
+	}
+
+	msg := queue.NewMessage(body)
+	msg.Id = m.MessageId
+	msg.LockToken = m.PopReceipt
+	msg.DeliveryCount = m.DequeueCount
+
+	if t, err := time.Parse(queue.Rfc2616Time, m.InsertionTime); err == nil {
+		msg.EnqueuedTimeUtc = t
+	}
+
+	if t, err := time.Parse(queue.Rfc2616Time, m.TimeNextVisible); err == nil {
+		msg.LockedUntilUtc = t
+	}
+
+	if t, err := time.Parse(queue.Rfc2616Time, m.ExpirationTime); err == nil && !msg.EnqueuedTimeUtc.IsZero() {
+		msg.TimeToLive = int(t.Sub(msg.EnqueuedTimeUtc) / time.Second)
+	}
+
+	return msg, nil
+}
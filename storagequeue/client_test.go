@@ -0,0 +1,165 @@
+package storagequeue
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	queue "github.com/g-rad/go-azurequeue"
+)
+
+var q = &QueueClient{
+	AccountName: "account",
+	AccountKey:  base64.StdEncoding.EncodeToString([]byte("key")),
+	QueueName:   "test",
+}
+
+const messagesXml = `<?xml version="1.0" encoding="utf-8"?>
+<QueueMessagesList>
+  <QueueMessage>
+    <MessageId>5974b586-0df3-4e2d-ad0c-18e3892bfca2</MessageId>
+    <InsertionTime>Fri, 09 Oct 2009 21:04:30 GMT</InsertionTime>
+    <ExpirationTime>Fri, 16 Oct 2009 21:04:30 GMT</ExpirationTime>
+    <PopReceipt>YzQ4Yzg1MDItYTc0Ny00OWNjLTkxYTUtZGM0MDFiZDAwYzEw</PopReceipt>
+    <TimeNextVisible>Fri, 09 Oct 2009 23:29:20 GMT</TimeNextVisible>
+    <DequeueCount>2</DequeueCount>
+    <MessageText>SGVsbG8h</MessageText>
+  </QueueMessage>
+</QueueMessagesList>`
+
+type fakeHttpClient func(req *http.Request) (*http.Response, error)
+
+func (f fakeHttpClient) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func respondWith(code int, body string) fakeHttpClient {
+	return func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: code,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+		}, nil
+	}
+}
+
+func Test_stringToSign(t *testing.T) {
+
+	date := time.Date(2018, 1, 1, 1, 1, 1, 0, time.UTC)
+	req, err := q.createRequest("PUT", "messages/abc", map[string][]string{
+		"visibilitytimeout": {"0"},
+		"popreceipt":        {"xyz"},
+	}, nil, date)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "PUT\n\n\n\n\n\n\n\n\n\n\n\n" +
+		"x-ms-date:Mon, 01 Jan 2018 01:01:01 GMT\n" +
+		"x-ms-version:" + storageVersion + "\n" +
+		"/account/test/messages/abc\npopreceipt:xyz\nvisibilitytimeout:0"
+
+	if s := q.stringToSign(req); s != expected {
+		t.Fatalf("Expected string to sign %q but got %q", expected, s)
+	}
+
+	if auth := req.Header.Get("Authorization"); !strings.HasPrefix(auth, "SharedKey account:") {
+		t.Fatalf("Expected SharedKey authorization header but got %s", auth)
+	}
+}
+
+func Test_GetMessage(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	SetHttpClient(respondWith(200, messagesXml))
+
+	msg, err := q.GetMessage()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(msg.Body) != "Hello!" {
+		t.Fatalf("Expected body %s but got %s", "Hello!", string(msg.Body))
+	}
+
+	if msg.Id != "5974b586-0df3-4e2d-ad0c-18e3892bfca2" {
+		t.Fatalf("Expected Id %s but got %s", "5974b586-0df3-4e2d-ad0c-18e3892bfca2", msg.Id)
+	}
+
+	if msg.LockToken != "YzQ4Yzg1MDItYTc0Ny00OWNjLTkxYTUtZGM0MDFiZDAwYzEw" {
+		t.Fatalf("Expected LockToken to be the pop receipt but got %s", msg.LockToken)
+	}
+
+	if msg.DeliveryCount != 2 {
+		t.Fatalf("Expected DeliveryCount %v but got %v", 2, msg.DeliveryCount)
+	}
+
+	if msg.TimeToLive != 7*24*60*60 {
+		t.Fatalf("Expected TimeToLive %v but got %v", 7*24*60*60, msg.TimeToLive)
+	}
+}
+
+func Test_GetMessage_empty(t *testing.T) {
+
+	defer SetHttpClient(nil)
+	SetHttpClient(respondWith(200, "<QueueMessagesList></QueueMessagesList>"))
+
+	_, err := q.GetMessage()
+
+	if _, ok := err.(queue.NoMessagesAvailableError); !ok {
+		t.Fatalf("Expected error type NoMessagesAvailableError but got %v", err)
+	}
+}
+
+func Test_SendMessage(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	var sent string
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		body, _ := ioutil.ReadAll(req.Body)
+		sent = string(body)
+		return respondWith(201, "")(req)
+	}))
+
+	if err := q.SendMessage(queue.NewMessage([]byte("Hello!"))); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "<QueueMessage><MessageText>SGVsbG8h</MessageText></QueueMessage>"
+	if sent != expected {
+		t.Fatalf("Expected body %s but got %s", expected, sent)
+	}
+}
+
+func Test_handleStatusCode(t *testing.T) {
+
+	tests := []struct {
+		code  int
+		body  string
+		error reflect.Type
+	}{
+		{400, "", reflect.TypeOf(queue.BadRequestError{})},
+		{401, "", reflect.TypeOf(queue.NotAuthorizedError{})},
+		{403, "", reflect.TypeOf(queue.ForbiddenError{})},
+		{404, "<Code>QueueNotFound</Code>", reflect.TypeOf(queue.QueueDontExistError{})},
+		{404, "<Code>MessageNotFound</Code>", reflect.TypeOf(queue.MessageDontExistError{})},
+		{500, "", reflect.TypeOf(queue.InternalError{})},
+		{503, "", reflect.TypeOf(queue.UnknownStatusError{})},
+	}
+
+	for _, test := range tests {
+		err := handleStatusCode(test.code, []byte(test.body))
+
+		if reflect.TypeOf(err) != test.error {
+			t.Fatalf("Expected error type %s but got %s", test.error, reflect.TypeOf(err))
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package queue
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Properties of a topic. Zero durations and sizes stand for the service defaults.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/topics
+type TopicDescription struct {
+	// Time to live of messages which don't specify one.
+	DefaultMessageTimeToLive time.Duration
+
+	// Maximal size of the topic.
+	MaxSizeInMegabytes int
+
+	// Whether duplicate messages are detected by MessageId. Can't be changed after creation.
+	RequiresDuplicateDetection bool
+}
+
+// Properties of a topic subscription. Zero durations and counts stand for the service defaults.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/subscriptions
+type SubscriptionDescription struct {
+	// How long a received message stays locked, at most 5 minutes.
+	LockDuration time.Duration
+
+	// Whether the subscription requires sessions. Can't be changed after creation.
+	RequiresSession bool
+
+	// Time to live of messages which don't specify one.
+	DefaultMessageTimeToLive time.Duration
+
+	// Whether expired messages are moved to the dead-letter queue.
+	DeadLetteringOnMessageExpiration bool
+
+	// Number of deliveries after which a message is dead-lettered.
+	MaxDeliveryCount int
+
+	// Rules selecting the messages of the subscription. If any are given, they replace
+	// all other rules of the subscription, including the $Default rule matching everything.
+	Rules []Rule
+}
+
+// Rule of a subscription.
+type Rule struct {
+	Name string
+
+	// SQL filter expression e.g. "Region = 'EU'"
+	Filter string
+
+	// Optional SQL action expression e.g. "SET Priority = 'High'"
+	Action string
+}
+
+// Creates the topic named by QueueName if it doesn't exist, or updates the properties
+// which differ from desc if it does. See EnsureQueue.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/create-topic
+func (q *QueueClient) EnsureTopic(ctx context.Context, desc TopicDescription) (EnsureAction, error) {
+
+	body, err := q.manage(ctx, "get_topic", "GET", q.QueueName, nil, nil)
+	if err != nil {
+		return EnsureUnchanged, err
+	}
+
+	entry := topicEntry{}
+	err = parseEntry(body, &entry)
+
+	if _, missing := err.(QueueDontExistError); missing {
+		d := topicDescriptionXml{}
+		d.apply(desc)
+		if err := q.putEntity(ctx, "create_topic", q.QueueName, d, nil); err != nil {
+			return EnsureUnchanged, err
+		}
+		return EnsureCreated, nil
+	}
+
+	if err != nil {
+		return EnsureUnchanged, err
+	}
+
+	current := entry.Content.Description
+	ttl, err := parseDuration(current.DefaultMessageTimeToLive)
+	if err != nil {
+		return EnsureUnchanged, err
+	}
+
+	if desc.RequiresDuplicateDetection != isTrue(current.RequiresDuplicateDetection) {
+		return EnsureUnchanged, fmt.Errorf("Topic %s can't be updated, RequiresDuplicateDetection can't be changed after creation", q.QueueName)
+	}
+
+	if (desc.DefaultMessageTimeToLive == 0 || desc.DefaultMessageTimeToLive == ttl) &&
+		(desc.MaxSizeInMegabytes == 0 || desc.MaxSizeInMegabytes == current.MaxSizeInMegabytes) {
+		return EnsureUnchanged, nil
+	}
+
+	current.clearReadOnly()
+	current.apply(desc)
+	if err := q.updateEntity(ctx, "update_topic", q.QueueName, body, current); err != nil {
+		return EnsureUnchanged, err
+	}
+	return EnsureUpdated, nil
+}
+
+// Creates the subscription of the topic named by QueueName if it doesn't exist, or updates
+// the properties which differ from desc if it does. If desc has rules, rules which
+// differ are replaced and rules not in desc are deleted. See EnsureQueue.
+//
+// For more information see https://docs.microsoft.com/en-us/rest/api/servicebus/create-subscription
+func (q *QueueClient) EnsureSubscription(ctx context.Context, name string, desc SubscriptionDescription) (EnsureAction, error) {
+
+	path := q.QueueName + "/subscriptions/" + name
+
+	body, err := q.manage(ctx, "get_subscription", "GET", path, nil, nil)
+	if err != nil {
+		return EnsureUnchanged, err
+	}
+
+	entry := subscriptionEntry{}
+	err = parseEntry(body, &entry)
+
+	action := EnsureUnchanged
+
+	if _, missing := err.(QueueDontExistError); missing {
+		d := subscriptionDescriptionXml{}
+		d.apply(desc)
+		if err := q.putEntity(ctx, "create_subscription", path, d, nil); err != nil {
+			return EnsureUnchanged, err
+		}
+		action = EnsureCreated
+	} else if err != nil {
+		return EnsureUnchanged, err
+	} else {
+		current := entry.Content.Description
+		mutable, immutable, err := current.drift(desc)
+		if err != nil {
+			return EnsureUnchanged, err
+		}
+		if len(immutable) > 0 {
+			return EnsureUnchanged, fmt.Errorf("Subscription %s can't be updated, %s can't be changed after creation", path, strings.Join(immutable, ", "))
+		}
+		if len(mutable) > 0 {
+			current.clearReadOnly()
+			current.apply(desc)
+			if err := q.updateEntity(ctx, "update_subscription", path, body, current); err != nil {
+				return EnsureUnchanged, err
+			}
+			action = EnsureUpdated
+		}
+	}
+
+	if len(desc.Rules) == 0 {
+		return action, nil
+	}
+
+	changed, err := q.ensureRules(ctx, path, desc.Rules)
+	if err != nil {
+		return action, err
+	}
+
+	if changed && action == EnsureUnchanged {
+		action = EnsureUpdated
+	}
+	return action, nil
+}
+
+// Makes the rules of the subscription at path match rules. Reports whether any were changed.
+func (q *QueueClient) ensureRules(ctx context.Context, path string, rules []Rule) (bool, error) {
+
+	body, err := q.manage(ctx, "get_rules", "GET", path+"/rules", nil, nil)
+	if err != nil {
+		return false, err
+	}
+
+	feed := ruleFeed{}
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return false, wrap(err, "Error parsing rules")
+	}
+
+	current := map[string]Rule{}
+	for _, e := range feed.Entries {
+		r := e.Content.Description
+		current[e.Title] = Rule{Name: e.Title, Filter: r.Filter.SqlExpression, Action: r.Action.SqlExpression}
+	}
+
+	changed := false
+	wanted := map[string]bool{}
+
+	for _, rule := range rules {
+		wanted[rule.Name] = true
+
+		existing, ok := current[rule.Name]
+		if ok && existing == rule {
+			continue
+		}
+
+		// rules can't be updated, only replaced
+		if ok {
+			if _, err := q.manage(ctx, "delete_rule", "DELETE", path+"/rules/"+rule.Name, nil, nil); err != nil {
+				return changed, err
+			}
+		}
+
+		if err := q.putEntity(ctx, "create_rule", path+"/rules/"+rule.Name, newRuleDescriptionXml(rule), nil); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+
+	for name := range current {
+		if wanted[name] {
+			continue
+		}
+		if _, err := q.manage(ctx, "delete_rule", "DELETE", path+"/rules/"+name, nil, nil); err != nil {
+			return changed, err
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// Creates or, with an If-Match header, updates the entity at path.
+func (q *QueueClient) putEntity(ctx context.Context, operation string, path string, description interface{}, header http.Header) error {
+
+	body, err := marshalEntry(description)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.manage(ctx, operation, "PUT", path, header, body)
+	return err
+}
+
+type topicDescriptionXml struct {
+	XMLName                       xml.Name `xml:"http://schemas.microsoft.com/netservices/2010/10/servicebus/connect TopicDescription"`
+	DefaultMessageTimeToLive      string   `xml:"DefaultMessageTimeToLive,omitempty"`
+	MaxSizeInMegabytes            int      `xml:"MaxSizeInMegabytes,omitempty"`
+	RequiresDuplicateDetection    *bool    `xml:"RequiresDuplicateDetection,omitempty"`
+	DuplicateDetectionHistoryTime string   `xml:"DuplicateDetectionHistoryTimeWindow,omitempty"`
+	EnableBatchedOperations       *bool    `xml:"EnableBatchedOperations,omitempty"`
+	SizeInBytes                   int64    `xml:"SizeInBytes,omitempty"`
+	Status                        string   `xml:"Status,omitempty"`
+	CreatedAt                     string   `xml:"CreatedAt,omitempty"`
+	UpdatedAt                     string   `xml:"UpdatedAt,omitempty"`
+	SupportOrdering               *bool    `xml:"SupportOrdering,omitempty"`
+	AutoDeleteOnIdle              string   `xml:"AutoDeleteOnIdle,omitempty"`
+	EnablePartitioning            *bool    `xml:"EnablePartitioning,omitempty"`
+}
+
+type topicEntry struct {
+	XMLName xml.Name `xml:"entry"`
+	Content struct {
+		Description topicDescriptionXml `xml:"TopicDescription"`
+	} `xml:"content"`
+}
+
+func (d *topicDescriptionXml) apply(desc TopicDescription) {
+
+	if desc.DefaultMessageTimeToLive > 0 {
+		d.DefaultMessageTimeToLive = formatDuration(desc.DefaultMessageTimeToLive)
+	}
+	if desc.MaxSizeInMegabytes > 0 {
+		d.MaxSizeInMegabytes = desc.MaxSizeInMegabytes
+	}
+	d.RequiresDuplicateDetection = &desc.RequiresDuplicateDetection
+}
+
+func (d *topicDescriptionXml) clearReadOnly() {
+	d.SizeInBytes = 0
+	d.CreatedAt = ""
+	d.UpdatedAt = ""
+}
+
+type subscriptionDescriptionXml struct {
+	XMLName                          xml.Name `xml:"http://schemas.microsoft.com/netservices/2010/10/servicebus/connect SubscriptionDescription"`
+	LockDuration                     string   `xml:"LockDuration,omitempty"`
+	RequiresSession                  *bool    `xml:"RequiresSession,omitempty"`
+	DefaultMessageTimeToLive         string   `xml:"DefaultMessageTimeToLive,omitempty"`
+	DeadLetteringOnMessageExpiration *bool    `xml:"DeadLetteringOnMessageExpiration,omitempty"`
+	DeadLetteringOnFilterEvaluation  *bool    `xml:"DeadLetteringOnFilterEvaluationExceptions,omitempty"`
+	MessageCount                     int64    `xml:"MessageCount,omitempty"`
+	MaxDeliveryCount                 int      `xml:"MaxDeliveryCount,omitempty"`
+	EnableBatchedOperations          *bool    `xml:"EnableBatchedOperations,omitempty"`
+	Status                           string   `xml:"Status,omitempty"`
+	ForwardTo                        string   `xml:"ForwardTo,omitempty"`
+	CreatedAt                        string   `xml:"CreatedAt,omitempty"`
+	UpdatedAt                        string   `xml:"UpdatedAt,omitempty"`
+	AccessedAt                       string   `xml:"AccessedAt,omitempty"`
+	AutoDeleteOnIdle                 string   `xml:"AutoDeleteOnIdle,omitempty"`
+	ForwardDeadLetteredMessagesTo    string   `xml:"ForwardDeadLetteredMessagesTo,omitempty"`
+}
+
+type subscriptionEntry struct {
+	XMLName xml.Name `xml:"entry"`
+	Content struct {
+		Description subscriptionDescriptionXml `xml:"SubscriptionDescription"`
+	} `xml:"content"`
+}
+
+func (d *subscriptionDescriptionXml) apply(desc SubscriptionDescription) {
+
+	if desc.LockDuration > 0 {
+		d.LockDuration = formatDuration(desc.LockDuration)
+	}
+	if desc.DefaultMessageTimeToLive > 0 {
+		d.DefaultMessageTimeToLive = formatDuration(desc.DefaultMessageTimeToLive)
+	}
+	if desc.MaxDeliveryCount > 0 {
+		d.MaxDeliveryCount = desc.MaxDeliveryCount
+	}
+	d.RequiresSession = &desc.RequiresSession
+	d.DeadLetteringOnMessageExpiration = &desc.DeadLetteringOnMessageExpiration
+}
+
+func (d *subscriptionDescriptionXml) clearReadOnly() {
+	d.MessageCount = 0
+	d.CreatedAt = ""
+	d.UpdatedAt = ""
+	d.AccessedAt = ""
+}
+
+// Returns the names of the properties given in desc which differ from d,
+// separately for mutable and immutable ones.
+func (d subscriptionDescriptionXml) drift(desc SubscriptionDescription) (mutable []string, immutable []string, err error) {
+
+	lock, err := parseDuration(d.LockDuration)
+	if err != nil {
+		return nil, nil, err
+	}
+	ttl, err := parseDuration(d.DefaultMessageTimeToLive)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if desc.LockDuration > 0 && desc.LockDuration != lock {
+		mutable = append(mutable, "LockDuration")
+	}
+	if desc.DefaultMessageTimeToLive > 0 && desc.DefaultMessageTimeToLive != ttl {
+		mutable = append(mutable, "DefaultMessageTimeToLive")
+	}
+	if desc.DeadLetteringOnMessageExpiration != isTrue(d.DeadLetteringOnMessageExpiration) {
+		mutable = append(mutable, "DeadLetteringOnMessageExpiration")
+	}
+	if desc.MaxDeliveryCount > 0 && desc.MaxDeliveryCount != d.MaxDeliveryCount {
+		mutable = append(mutable, "MaxDeliveryCount")
+	}
+	if desc.RequiresSession != isTrue(d.RequiresSession) {
+		immutable = append(immutable, "RequiresSession")
+	}
+
+	return mutable, immutable, nil
+}
+
+type ruleDescriptionXml struct {
+	XMLName xml.Name `xml:"http://schemas.microsoft.com/netservices/2010/10/servicebus/connect RuleDescription"`
+	Filter  struct {
+		Type          string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+		SqlExpression string `xml:"SqlExpression"`
+	} `xml:"Filter"`
+	Action struct {
+		Type          string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+		SqlExpression string `xml:"SqlExpression,omitempty"`
+	} `xml:"Action"`
+	Name string `xml:"Name"`
+}
+
+func newRuleDescriptionXml(rule Rule) ruleDescriptionXml {
+
+	d := ruleDescriptionXml{Name: rule.Name}
+	d.Filter.Type = "SqlFilter"
+	d.Filter.SqlExpression = rule.Filter
+	d.Action.Type = "EmptyRuleAction"
+	if rule.Action != "" {
+		d.Action.Type = "SqlRuleAction"
+		d.Action.SqlExpression = rule.Action
+	}
+	return d
+}
+
+type ruleFeed struct {
+	Entries []struct {
+		Title   string `xml:"title"`
+		Content struct {
+			Description ruleDescriptionXml `xml:"RuleDescription"`
+		} `xml:"content"`
+	} `xml:"entry"`
+}
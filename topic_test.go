@@ -0,0 +1,185 @@
+package queue
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Fakes the management endpoint keeping entities by path.
+type fakeNamespace struct {
+	entities map[string]string
+	requests []string
+}
+
+func (n *fakeNamespace) client() fakeHttpClient {
+	return func(req *http.Request) (*http.Response, error) {
+		path := strings.TrimPrefix(req.URL.Path, "/")
+		n.requests = append(n.requests, req.Method+" "+path)
+
+		switch req.Method {
+		case "GET":
+			if strings.HasSuffix(path, "/rules") {
+				feed := `<feed xmlns="http://www.w3.org/2005/Atom">`
+				for p, entry := range n.entities {
+					if strings.HasPrefix(p, path+"/") {
+						feed += entry
+					}
+				}
+				return respondWith(200, feed+`</feed>`)(req)
+			}
+			if entry, ok := n.entities[path]; ok {
+				return respondWith(200, entry)(req)
+			}
+			return respondWith(200, emptyFeedXml)(req)
+		case "PUT":
+			body, _ := ioutil.ReadAll(req.Body)
+			entry := strings.TrimPrefix(string(body), `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+			// the service adds the title to stored entities
+			title := path[strings.LastIndex(path, "/")+1:]
+			entry = strings.Replace(entry, `<content`, "<title>"+title+"</title><content", 1)
+			n.entities[path] = entry
+			return respondWith(201, "")(req)
+		case "DELETE":
+			delete(n.entities, path)
+			return respondWith(200, "")(req)
+		}
+		return respondWith(400, "")(req)
+	}
+}
+
+func Test_EnsureTopic(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	ns := &fakeNamespace{entities: map[string]string{}}
+	SetHttpClient(ns.client())
+
+	cli := &QueueClient{Namespace: "test", QueueName: "events"}
+
+	for _, expected := range []EnsureAction{EnsureCreated, EnsureUnchanged} {
+		action, err := cli.EnsureTopic(context.Background(), TopicDescription{DefaultMessageTimeToLive: time.Hour})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action != expected {
+			t.Fatalf("Expected action %v but got %v", expected, action)
+		}
+	}
+
+	action, err := cli.EnsureTopic(context.Background(), TopicDescription{DefaultMessageTimeToLive: 2 * time.Hour})
+	if err != nil || action != EnsureUpdated {
+		t.Fatalf("Expected topic to be updated but got %v %v", action, err)
+	}
+
+	if _, err := cli.EnsureTopic(context.Background(), TopicDescription{RequiresDuplicateDetection: true}); err == nil {
+		t.Fatalf("Expected error changing RequiresDuplicateDetection")
+	}
+}
+
+func Test_EnsureSubscription(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	ns := &fakeNamespace{entities: map[string]string{
+		"events/subscriptions/eu/rules/$Default": `<entry><title>$Default</title><content type="application/xml">` +
+			`<RuleDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect">` +
+			`<Filter><SqlExpression>1=1</SqlExpression></Filter><Name>$Default</Name></RuleDescription></content></entry>`,
+	}}
+	SetHttpClient(ns.client())
+
+	cli := &QueueClient{Namespace: "test", QueueName: "events"}
+	desc := SubscriptionDescription{
+		MaxDeliveryCount: 5,
+		Rules:            []Rule{{Name: "eu", Filter: "Region = 'EU'"}},
+	}
+
+	action, err := cli.EnsureSubscription(context.Background(), "eu", desc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if action != EnsureCreated {
+		t.Fatalf("Expected action %v but got %v", EnsureCreated, action)
+	}
+
+	var paths []string
+	for p := range ns.entities {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	if strings.Join(paths, " ") != "events/subscriptions/eu events/subscriptions/eu/rules/eu" {
+		t.Fatalf("Expected subscription with only the eu rule but got %v", paths)
+	}
+
+	if !strings.Contains(ns.entities["events/subscriptions/eu/rules/eu"], "<SqlExpression>Region = &#39;EU&#39;</SqlExpression>") {
+		t.Fatalf("Unexpected rule %v", ns.entities["events/subscriptions/eu/rules/eu"])
+	}
+
+	action, err = cli.EnsureSubscription(context.Background(), "eu", desc)
+	if err != nil || action != EnsureUnchanged {
+		t.Fatalf("Expected subscription to be unchanged but got %v %v", action, err)
+	}
+
+	desc.Rules[0].Filter = "Region = 'EU' OR Region = 'UK'"
+	ns.requests = nil
+
+	action, err = cli.EnsureSubscription(context.Background(), "eu", desc)
+	if err != nil || action != EnsureUpdated {
+		t.Fatalf("Expected subscription to be updated but got %v %v", action, err)
+	}
+
+	expected := "GET events/subscriptions/eu GET events/subscriptions/eu/rules DELETE events/subscriptions/eu/rules/eu PUT events/subscriptions/eu/rules/eu"
+	if strings.Join(ns.requests, " ") != expected {
+		t.Fatalf("Expected requests %v but got %v", expected, ns.requests)
+	}
+}
+
+func Test_EnsureTopic_keepsUnmodelledProperties(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	ns := &fakeNamespace{entities: map[string]string{
+		"events": `<entry xmlns="http://www.w3.org/2005/Atom"><title>events</title><content type="application/xml">` +
+			`<TopicDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect" xmlns:i="http://www.w3.org/2001/XMLSchema-instance">` +
+			`<DefaultMessageTimeToLive>PT1H</DefaultMessageTimeToLive><MaxSizeInMegabytes>1024</MaxSizeInMegabytes>` +
+			`<RequiresDuplicateDetection>false</RequiresDuplicateDetection>` +
+			`<AuthorizationRules><AuthorizationRule i:type="SharedAccessAuthorizationRule"><KeyName>send</KeyName></AuthorizationRule></AuthorizationRules>` +
+			`<UserMetadata>owner=billing</UserMetadata><EnableExpress>true</EnableExpress>` +
+			`</TopicDescription></content></entry>`,
+		"events/subscriptions/eu": `<entry xmlns="http://www.w3.org/2005/Atom"><title>eu</title><content type="application/xml">` +
+			`<SubscriptionDescription xmlns="http://schemas.microsoft.com/netservices/2010/10/servicebus/connect">` +
+			`<LockDuration>PT1M</LockDuration><RequiresSession>false</RequiresSession><MaxDeliveryCount>10</MaxDeliveryCount>` +
+			`<UserMetadata>owner=billing</UserMetadata>` +
+			`</SubscriptionDescription></content></entry>`,
+	}}
+	SetHttpClient(ns.client())
+
+	cli := &QueueClient{Namespace: "test", QueueName: "events"}
+
+	action, err := cli.EnsureTopic(context.Background(), TopicDescription{DefaultMessageTimeToLive: 2 * time.Hour})
+	if err != nil || action != EnsureUpdated {
+		t.Fatalf("Expected topic to be updated but got %v %v", action, err)
+	}
+
+	topic := ns.entities["events"]
+	for _, e := range []string{"<DefaultMessageTimeToLive>PT7200S</DefaultMessageTimeToLive>", "<KeyName>send</KeyName>", "<UserMetadata>owner=billing</UserMetadata>", "<EnableExpress>true</EnableExpress>"} {
+		if !strings.Contains(topic, e) {
+			t.Fatalf("Expected %s in the updated topic %s", e, topic)
+		}
+	}
+
+	action, err = cli.EnsureSubscription(context.Background(), "eu", SubscriptionDescription{MaxDeliveryCount: 5})
+	if err != nil || action != EnsureUpdated {
+		t.Fatalf("Expected subscription to be updated but got %v %v", action, err)
+	}
+
+	subscription := ns.entities["events/subscriptions/eu"]
+	if !strings.Contains(subscription, "<MaxDeliveryCount>5</MaxDeliveryCount><UserMetadata>owner=billing</UserMetadata>") {
+		t.Fatalf("Expected UserMetadata kept in the updated subscription %s", subscription)
+	}
+}
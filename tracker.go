@@ -0,0 +1,129 @@
+package queue
+
+import (
+	"sync"
+	"time"
+)
+
+// Outcome describes how a received message was settled.
+type Outcome int
+
+const (
+	// The message was deleted from the queue.
+	Completed Outcome = iota
+
+	// The message was unlocked for processing by other receivers.
+	Abandoned
+)
+
+func (o Outcome) String() string {
+	switch o {
+	case Completed:
+		return "Completed"
+	case Abandoned:
+		return "Abandoned"
+	}
+	return "Unknown"
+}
+
+// SettlementTracker records messages received by a QueueClient and how they were settled.
+// Implementations must be safe for concurrent use.
+type SettlementTracker interface {
+	// Called after a message has been received and locked.
+	Received(msg *Message)
+
+	// Called after a message has been successfully settled.
+	Settled(msg *Message, outcome Outcome)
+
+	// Returns the number of received messages which are not settled yet.
+	InFlight() int
+
+	// Returns how long ago the oldest unsettled message was received,
+	// or zero if there are no messages in flight.
+	OldestLockAge() time.Duration
+}
+
+// In-memory SettlementTracker. Messages whose lock has expired without being
+// settled are no longer counted as in flight, Service Bus has made them
+// available to other receivers.
+type MemoryTracker struct {
+	mu       sync.Mutex
+	inFlight map[string]trackedLock
+	counts   map[Outcome]int
+}
+
+type trackedLock struct {
+	received    time.Time
+	lockedUntil time.Time
+}
+
+func NewMemoryTracker() *MemoryTracker {
+
+	return &MemoryTracker{
+		inFlight: map[string]trackedLock{},
+		counts:   map[Outcome]int{},
+	}
+}
+
+func (t *MemoryTracker) Received(msg *Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+	t.inFlight[msg.LockToken] = trackedLock{received: time.Now(), lockedUntil: msg.LockedUntilUtc}
+}
+
+func (t *MemoryTracker) Settled(msg *Message, outcome Outcome) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.inFlight, msg.LockToken)
+	t.counts[outcome]++
+}
+
+func (t *MemoryTracker) InFlight() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+	return len(t.inFlight)
+}
+
+func (t *MemoryTracker) OldestLockAge() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+
+	var oldest time.Time
+	for _, lock := range t.inFlight {
+		if oldest.IsZero() || lock.received.Before(oldest) {
+			oldest = lock.received
+		}
+	}
+
+	if oldest.IsZero() {
+		return 0
+	}
+
+	return time.Since(oldest)
+}
+
+// Removes messages whose lock has expired. Must be called with mu held.
+func (t *MemoryTracker) prune() {
+
+	now := clock.Now()
+	for token, lock := range t.inFlight {
+		if !lock.lockedUntil.IsZero() && !now.Before(lock.lockedUntil) {
+			delete(t.inFlight, token)
+		}
+	}
+}
+
+// Returns the number of messages settled with the given outcome.
+func (t *MemoryTracker) Count(outcome Outcome) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.counts[outcome]
+}
@@ -0,0 +1,89 @@
+package queue
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func Test_MemoryTracker(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	tracker := NewMemoryTracker()
+	cli := &QueueClient{Namespace: "test", QueueName: "test", Tracker: tracker}
+
+	lockToken := 0
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		resp, _ := respondWith(200, "hello")(req)
+		if req.Method == "POST" {
+			lockToken++
+			resp.Header.Set(headerBrokerProperties, `{"MessageId":"id","LockToken":"`+strconv.Itoa(lockToken)+`"}`)
+		}
+		return resp, nil
+	}))
+
+	var msgs []*Message
+	for i := 0; i < 3; i++ {
+		msg, err := cli.GetMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	if tracker.InFlight() != 3 {
+		t.Fatalf("Expected 3 messages in flight but got %v", tracker.InFlight())
+	}
+
+	if tracker.OldestLockAge() <= 0 {
+		t.Fatalf("Expected positive oldest lock age but got %v", tracker.OldestLockAge())
+	}
+
+	cli.DeleteMessage(msgs[0])
+	cli.DeleteMessage(msgs[1])
+	cli.UnlockMessage(msgs[2])
+
+	if tracker.InFlight() != 0 {
+		t.Fatalf("Expected no messages in flight but got %v", tracker.InFlight())
+	}
+
+	if tracker.OldestLockAge() != 0 {
+		t.Fatalf("Expected zero oldest lock age but got %v", tracker.OldestLockAge())
+	}
+
+	if tracker.Count(Completed) != 2 {
+		t.Fatalf("Expected 2 completed messages but got %v", tracker.Count(Completed))
+	}
+
+	if tracker.Count(Abandoned) != 1 {
+		t.Fatalf("Expected 1 abandoned message but got %v", tracker.Count(Abandoned))
+	}
+}
+
+func Test_MemoryTracker_expiredLock(t *testing.T) {
+
+	now := time.Now()
+	SetClock(fixedClock(now))
+	defer SetClock(nil)
+
+	tracker := NewMemoryTracker()
+
+	tracker.Received(&Message{LockToken: "expired", LockedUntilUtc: now})
+	tracker.Received(&Message{LockToken: "locked", LockedUntilUtc: now.Add(time.Minute)})
+
+	if tracker.InFlight() != 1 {
+		t.Fatalf("Expected 1 message in flight but got %v", tracker.InFlight())
+	}
+
+	SetClock(fixedClock(now.Add(time.Minute)))
+
+	if tracker.InFlight() != 0 {
+		t.Fatalf("Expected no messages in flight but got %v", tracker.InFlight())
+	}
+
+	if tracker.OldestLockAge() != 0 {
+		t.Fatalf("Expected zero oldest lock age but got %v", tracker.OldestLockAge())
+	}
+}
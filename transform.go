@@ -0,0 +1,121 @@
+package queue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+// BodyTransform reversibly changes message bodies, e.g. compressing or encrypting them.
+// Transforms of a client are applied in order to sent messages and in reverse order
+// to received ones, so configure them as compress, then encrypt. Signing with the
+// client's Signer always happens last on send and first on receive.
+type BodyTransform interface {
+	// Transforms the body of a message about to be sent.
+	Encode(msg *Message) error
+
+	// Reverts Encode on the body of a received message.
+	Decode(msg *Message) error
+}
+
+// Returned when a body transform or codec fails on a received message. Message holds the
+// received message, which is still locked and has to be settled by the caller.
+type TransformError struct {
+	Message *Message
+	Err     error
+}
+
+func (e TransformError) Error() string {
+	return "Decoding body of message " + e.Message.Id + " failed: " + e.Err.Error()
+}
+
+// Content-Encoding of gzip compressed bodies.
+const ContentEncodingGzip = "gzip"
+
+// Compresses bodies with gzip and marks them with Content-Encoding gzip.
+// Messages which already have a Content-Encoding are sent as is, and only
+// received messages marked as gzip are decompressed.
+type GzipTransform struct {
+	// Compression level, defaults to gzip.DefaultCompression.
+	Level int
+}
+
+func (t GzipTransform) Encode(msg *Message) error {
+
+	if msg.ContentEncoding != "" {
+		return nil
+	}
+
+	level := t.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(msg.Body); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	msg.Body = buf.Bytes()
+	msg.ContentEncoding = ContentEncodingGzip
+	return nil
+}
+
+func (t GzipTransform) Decode(msg *Message) error {
+
+	if msg.ContentEncoding != ContentEncodingGzip {
+		return nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(msg.Body))
+	if err != nil {
+		return err
+	}
+
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	msg.Body = body
+	msg.ContentEncoding = ""
+	return nil
+}
+
+// Applies the client's transforms to a copy of a message about to be sent.
+func (q *QueueClient) encode(msg *Message) (*Message, error) {
+
+	if len(q.Transforms) == 0 {
+		return msg, nil
+	}
+
+	msg = msg.Clone()
+	for _, t := range q.Transforms {
+		if err := t.Encode(msg); err != nil {
+			return nil, wrap(err, "Encoding message body failed")
+		}
+	}
+
+	return msg, nil
+}
+
+// Reverts the client's transforms on a received message.
+func (q *QueueClient) decode(msg *Message) error {
+
+	for i := len(q.Transforms) - 1; i >= 0; i-- {
+		if err := q.Transforms[i].Decode(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
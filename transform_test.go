@@ -0,0 +1,69 @@
+package queue
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func Test_Transforms(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	aesKey := bytes.Repeat([]byte{7}, 32)
+	cli := QueueClient{Namespace: "test", KeyName: "key", KeyValue: "keyvalue", QueueName: "test"}
+	cli.Transforms = []BodyTransform{
+		GzipTransform{},
+		&JoseEnvelope{Kind: JoseJWE, KeyId: "aes", Key: aesKey, Keys: staticKeys{"aes": aesKey}, RequireToken: true},
+	}
+
+	var sent *http.Request
+	var sentBody []byte
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		sent = req
+		sentBody, _ = ioutil.ReadAll(req.Body)
+		return respondWith(201, "")(req)
+	}))
+
+	msg := NewMessage(bytes.Repeat([]byte("hello "), 100))
+	msg.ContentType = "text/plain"
+
+	if err := cli.SendMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent.Header.Get(headerContentType) != ContentTypeJose || sent.Header.Get(headerContentEncoding) != ContentEncodingGzip {
+		t.Fatalf("Expected compressed and encrypted body to be sent but got %s %s",
+			sent.Header.Get(headerContentType), sent.Header.Get(headerContentEncoding))
+	}
+
+	if msg.ContentEncoding != "" || msg.ContentType != "text/plain" {
+		t.Fatalf("Expected sent message to be unchanged")
+	}
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		resp, _ := respondWith(201, string(sentBody))(req)
+		resp.Header = sent.Header
+		return resp, nil
+	}))
+
+	received, err := cli.GetMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(received.Body, msg.Body) || received.ContentType != "text/plain" || received.ContentEncoding != "" {
+		t.Fatalf("Expected original body but got %s %s %s", received.ContentType, received.ContentEncoding, received.Body)
+	}
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		return respondWith(201, "plain")(req)
+	}))
+
+	if _, err := cli.GetMessage(); err == nil {
+		t.Fatalf("Expected TransformError for a message which isn't encrypted")
+	} else if _, ok := err.(TransformError); !ok {
+		t.Fatalf("Expected TransformError but got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package queue
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+)
+
+// Establishes a TLS connection to the service before the first real operation,
+// so the first message after a deploy doesn't pay for DNS, TCP and TLS handshakes.
+// The connection is kept for reuse by subsequent requests. SAS tokens are signed
+// locally for every request, so there is no token to fetch ahead.
+//
+// Returns an error if the service can't be reached; the response status is not
+// checked since the key may lack the rights to read the queue.
+func (q *QueueClient) WarmUp(ctx context.Context) error {
+
+	if err := q.checkClosed(); err != nil {
+		return err
+	}
+
+	req, err := q.createRequest("", "HEAD")
+	if err != nil {
+		return wrap(err, "Request create failed")
+	}
+
+	resp, err := q.do("warm_up", q.getClient(), req.WithContext(ctx))
+	if err != nil {
+		q.countError("warm_up", err)
+		return wrap(err, "Warming up connection failed")
+	}
+
+	// the connection is only reused once the body is read to the end
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	q.logResponse("warm_up", nil, resp.StatusCode, nil)
+	return nil
+}
@@ -0,0 +1,35 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func Test_WarmUp(t *testing.T) {
+
+	defer SetHttpClient(nil)
+
+	var sent *http.Request
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		sent = req
+		return respondWith(401, "")(req)
+	}))
+
+	if err := q.WarmUp(context.Background()); err != nil {
+		t.Fatalf("Expected warm up to ignore the status but got %v", err)
+	}
+
+	if sent.Method != "HEAD" || sent.URL.Host != "test.servicebus.windows.net:443" || sent.Header.Get("Authorization") == "" {
+		t.Fatalf("Expected signed HEAD request to the namespace but got %v %v", sent.Method, sent.URL)
+	}
+
+	SetHttpClient(fakeHttpClient(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("no such host")
+	}))
+
+	if err := q.WarmUp(context.Background()); err == nil {
+		t.Fatalf("Expected unreachable service to fail warm up")
+	}
+}